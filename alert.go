@@ -1,13 +1,25 @@
 package coreapi
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
 
+// Alert levels
+const (
+	AlertLevelSuccess = "success"
+	AlertLevelWarning = "warn"
+	AlertLevelError   = "error"
+)
+
 type AlertOptions struct {
 	Channels []string          `json:"channels"`
 	Quiet    bool              `json:"quiet"`
@@ -15,6 +27,43 @@ type AlertOptions struct {
 	Image    string            `json:"image"`
 	Fields   map[string]string `json:"fields"`
 	Escalate map[int][]string  `json:"escalate"`
+	// ImageData is a raw image, e.g. the result of ModuleChart.Render. It is attached to the alert as a data URI
+	// in the request query. The base64 and query escaping make the image about 1.4 times larger, so the image up to
+	// about 180 KB fits DefaultMaxAlertURLLength, see ModuleAlert.WithMaxURLLength.
+	ImageData []byte `json:"image_data"`
+	// Chart is rendered with the chart module and attached to the alert as a data URI.
+	Chart *AlertChart `json:"chart"`
+}
+
+// AlertChart describes a chart, which is rendered on the fly and attached to the alert.
+type AlertChart struct {
+	Title   string        `json:"title"`
+	Series  []DataSeries  `json:"series"`
+	Options *ChartOptions `json:"options,omitempty"`
+}
+
+// ImageDataURI returns the data URI for the image. The media type is detected by the image content.
+func ImageDataURI(img []byte) string {
+	mediaType := http.DetectContentType(img)
+	if isSVG(img) {
+		mediaType = "image/svg+xml"
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(img)
+}
+
+// isSVG reports whether the image is the SVG document, which is not detected by http.DetectContentType
+func isSVG(img []byte) bool {
+	head := img
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	head = bytes.TrimSpace(head)
+	if bytes.HasPrefix(head, []byte("<?xml")) {
+		if i := bytes.Index(head, []byte("?>")); i >= 0 {
+			head = bytes.TrimSpace(head[i+2:])
+		}
+	}
+	return bytes.HasPrefix(head, []byte("<svg"))
 }
 
 type Alert struct {
@@ -25,27 +74,85 @@ type Alert struct {
 	Count      int       `json:"count"`
 }
 
+// Duration returns the time passed since the alert start. It returns zero for the nil alert.
+func (a *Alert) Duration() time.Duration {
+	if a == nil || a.Start.IsZero() {
+		return 0
+	}
+	return time.Since(a.Start)
+}
+
+// DefaultMaxAlertURLLength is the default limit of the alert request path length with the attached image.
+// The image is sent in the query argument, so the data URI of the ImageData or the Chart option makes the request
+// line long. The Go HTTP server accepts the request headers up to 1 MB by default, the proxies often have lower limits.
+const DefaultMaxAlertURLLength = 256 * 1024
+
+// ErrAlertURLTooLong is returned, if the alert request path is longer than the limit, see WithMaxURLLength.
+var ErrAlertURLTooLong = errors.New("alert request path is too long")
+
 type ModuleAlert struct {
-	rf requestFunc
+	rf           requestFunc
+	silencer     *Silencer
+	maxURLLength int
+}
+
+// WithSilencer returns the alert module, which applies the silences to the alert calls.
+// The alerts matched by the silence are sent with the Quiet option or, for suppressing silences, are not sent at all.
+// The suppressed call returns the nil alert and false.
+func (m ModuleAlert) WithSilencer(s *Silencer) ModuleAlert {
+	m.silencer = s
+	return m
+}
+
+// WithMaxURLLength returns the alert module with the alert request path length limit, DefaultMaxAlertURLLength by default.
+// The limit is applied to the alerts with the ImageData or Chart option. The alert with the longer path
+// is not sent and ErrAlertURLTooLong is returned. The Chart option is checked before the chart is rendered too.
+// The limit <= 0 means the default one.
+func (m ModuleAlert) WithMaxURLLength(n int) ModuleAlert {
+	m.maxURLLength = n
+	return m
 }
 
 // Success calls alert module with success level.
 func (m ModuleAlert) Success(alertName, message string, opts *AlertOptions) (*Alert, bool, error) {
-	return m.call("success", alertName, message, opts)
+	return m.call(AlertLevelSuccess, alertName, message, opts)
 }
 
 // Error calls alert module with error level.
 func (m ModuleAlert) Error(alertName, message string, opts *AlertOptions) (*Alert, bool, error) {
-	return m.call("error", alertName, message, opts)
+	return m.call(AlertLevelError, alertName, message, opts)
 }
 
 // Warning calls alert module with warning level.
 func (m ModuleAlert) Warning(alertName, message string, opts *AlertOptions) (*Alert, bool, error) {
-	return m.call("warn", alertName, message, opts)
+	return m.call(AlertLevelWarning, alertName, message, opts)
 }
 
 func (m ModuleAlert) call(method, alertName, message string, opts *AlertOptions) (*Alert, bool, error) {
-	u := fmt.Sprintf("alert/%s/%s", method, alertName)
+	name, errName := escapeName(alertName)
+	if errName != nil {
+		return nil, false, errName
+	}
+
+	if m.silencer != nil {
+		var fields map[string]string
+		if opts != nil {
+			fields = opts.Fields
+		}
+		if silence := m.silencer.Match(alertName, fields); silence != nil {
+			if silence.Suppress {
+				return nil, false, nil
+			}
+			o := AlertOptions{}
+			if opts != nil {
+				o = *opts
+			}
+			o.Quiet = true
+			opts = &o
+		}
+	}
+
+	u := fmt.Sprintf("alert/%s/%s", method, name)
 
 	if opts != nil {
 		args := url.Values{}
@@ -58,14 +165,12 @@ func (m ModuleAlert) call(method, alertName, message string, opts *AlertOptions)
 		if opts.Repeat > 0 {
 			args.Add("repeat", fmt.Sprintf("%d", opts.Repeat))
 		}
-		if opts.Image != "" {
-			args.Add("image", opts.Image)
-		}
 		if len(opts.Fields) > 0 {
 			var fields []string
 			for k, v := range opts.Fields {
 				fields = append(fields, fmt.Sprintf("%s:%s", k, v))
 			}
+			sort.Strings(fields)
 			args.Add("fields", strings.Join(fields, ","))
 		}
 		if len(opts.Escalate) > 0 {
@@ -73,12 +178,34 @@ func (m ModuleAlert) call(method, alertName, message string, opts *AlertOptions)
 			for k, v := range opts.Escalate {
 				escalate = append(escalate, fmt.Sprintf("%d:%s", k, strings.Join(v, ",")))
 			}
+			sort.Strings(escalate)
 			args.Add("escalate", strings.Join(escalate, ";"))
 		}
 
+		if opts.Chart != nil {
+			// the request path without the image is checked before the chart is rendered
+			if err := m.checkURLLength(len(u) + len("?&image=") + len(args.Encode())); err != nil {
+				return nil, false, err
+			}
+		}
+		image, errImage := m.image(opts)
+		if errImage != nil {
+			return nil, false, errImage
+		}
+		if image != "" {
+			args.Add("image", image)
+		}
+
 		if len(args) > 0 {
 			u = fmt.Sprintf("%s?%s", u, args.Encode())
 		}
+
+		// only the attached image data makes the request path long
+		if strings.HasPrefix(image, "data:") {
+			if err := m.checkURLLength(len(u)); err != nil {
+				return nil, false, err
+			}
+		}
 	}
 
 	resp, err := m.rf(u, "text/plain", []byte(message))
@@ -99,8 +226,57 @@ func (m ModuleAlert) call(method, alertName, message string, opts *AlertOptions)
 	return rsp.Alert, rsp.LevelWasUpdated, nil
 }
 
+// checkURLLength returns ErrAlertURLTooLong, if the alert request path length n exceeds the limit
+func (m ModuleAlert) checkURLLength(n int) error {
+	maxURLLength := m.maxURLLength
+	if maxURLLength <= 0 {
+		maxURLLength = DefaultMaxAlertURLLength
+	}
+	if n > maxURLLength {
+		return fmt.Errorf("%w: %d bytes, the limit is %d bytes, use the smaller image or the Image URL",
+			ErrAlertURLTooLong, n, maxURLLength)
+	}
+	return nil
+}
+
+// image returns the image argument for the alert options.
+// Only one of Image, ImageData and Chart may be defined.
+func (m ModuleAlert) image(opts *AlertOptions) (string, error) {
+	defined := 0
+	if opts.Image != "" {
+		defined++
+	}
+	if len(opts.ImageData) > 0 {
+		defined++
+	}
+	if opts.Chart != nil {
+		defined++
+	}
+	if defined > 1 {
+		return "", fmt.Errorf("only one of Image, ImageData and Chart options may be defined")
+	}
+
+	switch {
+	case len(opts.ImageData) > 0:
+		return ImageDataURI(opts.ImageData), nil
+	case opts.Chart != nil:
+		img, err := ModuleChart{rf: m.rf}.RenderWithOptions(opts.Chart.Title, opts.Chart.Series, opts.Chart.Options)
+		if err != nil {
+			return "", fmt.Errorf("failed to render alert chart: %w", err)
+		}
+		return ImageDataURI(img), nil
+	}
+
+	return opts.Image, nil
+}
+
 func (m ModuleAlert) Get(alertName string) (*Alert, error) {
-	u := fmt.Sprintf("alert/get/%s", alertName)
+	name, errName := escapeName(alertName)
+	if errName != nil {
+		return nil, errName
+	}
+
+	u := fmt.Sprintf("alert/get/%s", name)
 
 	resp, err := m.rf(u, "", nil)
 	if err != nil {
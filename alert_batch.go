@@ -0,0 +1,180 @@
+package coreapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const defaultAlertBatchConcurrency = 8
+
+// AlertUpdate is an alert update for the batch call.
+type AlertUpdate struct {
+	// Level is one of AlertLevelSuccess, AlertLevelWarning or AlertLevelError
+	Level   string        `json:"level"`
+	Name    string        `json:"name"`
+	Message string        `json:"message"`
+	Options *AlertOptions `json:"options,omitempty"`
+}
+
+// AlertUpdateResult is the result of the alert update in the batch call.
+type AlertUpdateResult struct {
+	Alert           *Alert
+	LevelWasUpdated bool
+	Error           error
+}
+
+// Batch sends the alert updates with the default concurrency. See BatchWithConcurrency.
+func (m ModuleAlert) Batch(updates []AlertUpdate) ([]AlertUpdateResult, error) {
+	return m.BatchWithConcurrency(updates, defaultAlertBatchConcurrency)
+}
+
+// BatchWithConcurrency sends the alert updates in one request, if the balerter server supports it.
+// Otherwise, the updates are sent one by one with at most concurrency parallel requests.
+// The updates are always sent one by one, if the alert module has a silencer.
+//
+// The results have the same order as the updates. The per-update errors are returned in the results,
+// the error is returned only if the batch request is failed at all.
+func (m ModuleAlert) BatchWithConcurrency(updates []AlertUpdate, concurrency int) ([]AlertUpdateResult, error) {
+	if len(updates) == 0 {
+		return nil, nil
+	}
+
+	// the images are resolved once, so the charts are not rendered again for the one by one fallback
+	resolved, results := m.resolveUpdates(updates)
+
+	if m.silencer == nil {
+		err := m.batch(resolved, results)
+		if err == nil {
+			return results, nil
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			return nil, err
+		}
+	}
+
+	m.fanOut(resolved, results, concurrency)
+
+	return results, nil
+}
+
+// resolveUpdates validates the updates and replaces their ImageData and Chart options with the Image.
+// The errors are set in the results, the failed updates are not sent.
+func (m ModuleAlert) resolveUpdates(updates []AlertUpdate) ([]AlertUpdate, []AlertUpdateResult) {
+	resolved := make([]AlertUpdate, len(updates))
+	results := make([]AlertUpdateResult, len(updates))
+
+	for i, u := range updates {
+		if err := validateAlertLevel(u.Level); err != nil {
+			results[i].Error = err
+			continue
+		}
+		if u.Options != nil {
+			image, err := m.image(u.Options)
+			if err != nil {
+				results[i].Error = err
+				continue
+			}
+			o := *u.Options
+			o.Image = image
+			o.ImageData = nil
+			o.Chart = nil
+			u.Options = &o
+		}
+		resolved[i] = u
+	}
+
+	return resolved, results
+}
+
+// batch sends the updates without the errors in the results with one request to the balerter server
+func (m ModuleAlert) batch(updates []AlertUpdate, results []AlertUpdateResult) error {
+	req := make([]AlertUpdate, 0, len(updates))
+	// idx maps the request item to the update index
+	idx := make([]int, 0, len(updates))
+
+	for i, u := range updates {
+		if results[i].Error != nil {
+			continue
+		}
+		req = append(req, u)
+		idx = append(idx, i)
+	}
+
+	if len(req) == 0 {
+		return nil
+	}
+
+	payload, errMarshal := json.Marshal(req)
+	if errMarshal != nil {
+		return fmt.Errorf("request marshal error, %w", errMarshal)
+	}
+
+	resp, err := m.rf("alert/batch", "application/json", payload)
+	if err != nil {
+		return fmt.Errorf("failed to call alert/batch: %w", err)
+	}
+
+	var rsp []struct {
+		Alert           *Alert `json:"alert"`
+		LevelWasUpdated bool   `json:"level_was_updated"`
+		Error           string `json:"error"`
+	}
+
+	errUnmarshal := json.Unmarshal(resp, &rsp)
+	if errUnmarshal != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", errUnmarshal)
+	}
+	if len(rsp) != len(req) {
+		return fmt.Errorf("unexpected batch response length %d, expect %d", len(rsp), len(req))
+	}
+
+	for j, r := range rsp {
+		i := idx[j]
+		results[i].Alert = r.Alert
+		results[i].LevelWasUpdated = r.LevelWasUpdated
+		if r.Error != "" {
+			results[i].Error = fmt.Errorf("%s", r.Error)
+		}
+	}
+
+	return nil
+}
+
+// fanOut sends the updates without the errors in the results one by one with bounded concurrency
+func (m ModuleAlert) fanOut(updates []AlertUpdate, results []AlertUpdateResult, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = defaultAlertBatchConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for i := range updates {
+		if results[i].Error != nil {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			u := updates[i]
+			results[i].Alert, results[i].LevelWasUpdated, results[i].Error = m.call(u.Level, u.Name, u.Message, u.Options)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func validateAlertLevel(level string) error {
+	switch level {
+	case AlertLevelSuccess, AlertLevelWarning, AlertLevelError:
+		return nil
+	}
+	return fmt.Errorf("unknown alert level %q", level)
+}
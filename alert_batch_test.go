@@ -0,0 +1,190 @@
+package coreapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestModuleAlert_Batch_server(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			if path != "alert/batch" {
+				t.Fatalf("unexpected path value, got %s", path)
+			}
+			if contentType != "application/json" {
+				t.Fatalf("unexpected contentType value, got %s", contentType)
+			}
+			expect := `[{"level":"error","name":"a","message":"m1"},` +
+				`{"level":"success","name":"b","message":"m2","options":{"channels":null,"quiet":true,"repeat":0,"image":"data:text/plain; charset=utf-8;base64,eA==","fields":null,"escalate":null,"image_data":null,"chart":null}}]`
+			if string(body) != expect {
+				t.Fatalf("unexpected body value, got %s", string(body))
+			}
+			return []byte(`[{"alert":{"name":"a","level":3},"level_was_updated":true},{"error":"err1"}]`), nil
+		},
+	}
+
+	results, err := m.Batch([]AlertUpdate{
+		{Level: AlertLevelError, Name: "a", Message: "m1"},
+		{Level: "bad", Name: "x", Message: "m"},
+		{Level: AlertLevelSuccess, Name: "b", Message: "m2", Options: &AlertOptions{Quiet: true, ImageData: []byte("x")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("unexpected results length, got %d", len(results))
+	}
+	if results[0].Error != nil || !results[0].LevelWasUpdated || results[0].Alert.Name != "a" {
+		t.Fatalf("unexpected result value, got %+v", results[0])
+	}
+	if results[1].Error == nil || results[1].Error.Error() != `unknown alert level "bad"` {
+		t.Fatalf("unexpected result value, got %+v", results[1])
+	}
+	if results[2].Error == nil || results[2].Error.Error() != "err1" {
+		t.Fatalf("unexpected result value, got %+v", results[2])
+	}
+}
+
+func TestModuleAlert_Batch_server_error(t *testing.T) {
+	for resp, expect := range map[string]string{
+		"bad":  "failed to unmarshal response: invalid character 'b' looking for beginning of value",
+		"[{}]": "unexpected batch response length 1, expect 2",
+	} {
+		resp := resp
+		m := ModuleAlert{
+			rf: func(path, contentType string, body []byte) ([]byte, error) {
+				return []byte(resp), nil
+			},
+		}
+
+		_, err := m.Batch([]AlertUpdate{{Level: AlertLevelError, Name: "a"}, {Level: AlertLevelError, Name: "b"}})
+		if err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		if err.Error() != expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			return nil, fmt.Errorf("err1")
+		},
+	}
+
+	_, err := m.Batch([]AlertUpdate{{Level: AlertLevelError, Name: "a"}})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to call alert/batch: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleAlert_Batch_fallback(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mu := sync.Mutex{}
+	calls := map[string]bool{}
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			if path == "alert/batch" {
+				return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+			}
+
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 5)
+
+			mu.Lock()
+			calls[path] = true
+			mu.Unlock()
+
+			if strings.HasSuffix(path, "/fail") {
+				return nil, fmt.Errorf("err1")
+			}
+			return []byte(`{"level_was_updated":true,"alert":{"name":"x"}}`), nil
+		},
+	}
+
+	var updates []AlertUpdate
+	for i := 0; i < 10; i++ {
+		updates = append(updates, AlertUpdate{Level: AlertLevelWarning, Name: fmt.Sprintf("a%d", i), Message: "m"})
+	}
+	updates = append(updates, AlertUpdate{Level: AlertLevelError, Name: "fail", Message: "m"})
+
+	results, err := m.BatchWithConcurrency(updates, 3)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(results) != 11 {
+		t.Fatalf("unexpected results length, got %d", len(results))
+	}
+	for i := 0; i < 10; i++ {
+		if results[i].Error != nil || !results[i].LevelWasUpdated {
+			t.Fatalf("unexpected result value, got %+v", results[i])
+		}
+	}
+	if results[10].Error == nil || results[10].Error.Error() != "failed to call alert/error/fail: err1" {
+		t.Fatalf("unexpected result value, got %+v", results[10])
+	}
+	if len(calls) != 11 {
+		t.Fatalf("unexpected calls count, got %d", len(calls))
+	}
+	if maxInFlight > 3 {
+		t.Fatalf("unexpected concurrency, got %d", maxInFlight)
+	}
+}
+
+func TestModuleAlert_Batch_fallback_chart(t *testing.T) {
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path)
+			switch path {
+			case "alert/batch":
+				return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+			case "chart/render":
+				return []byte(`"iVBORw0KGgo="`), nil
+			}
+			return []byte(`{"level_was_updated":true,"alert":{"name":"a"}}`), nil
+		},
+	}
+
+	results, err := m.Batch([]AlertUpdate{{Level: AlertLevelError, Name: "a", Message: "m", Options: &AlertOptions{
+		Chart: &AlertChart{Title: "t", Series: []DataSeries{{Data: []DataItem{{Timestamp: 1, Value: 2}}}}},
+	}}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if results[0].Error != nil {
+		t.Fatalf("unexpected result value, got %+v", results[0])
+	}
+
+	// the chart is rendered once for the batch request and the fallback
+	expect := []string{"chart/render", "alert/batch", "alert/error/a?image=data%3Aimage%2Fpng%3Bbase64%2CiVBORw0KGgo%3D"}
+	if fmt.Sprint(calls) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+}
+
+func TestModuleAlert_Batch_empty(t *testing.T) {
+	results, err := ModuleAlert{}.Batch(nil)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if results != nil {
+		t.Fatalf("unexpected results value, got %v", results)
+	}
+}
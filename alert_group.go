@@ -0,0 +1,312 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAlertGroupWindow        = time.Minute
+	defaultAlertGroupFlushInterval = time.Second * 10
+)
+
+// AlertGroupOptions are options for the AlertGrouper.
+type AlertGroupOptions struct {
+	// GroupBy is the alert field name, which value is used as the group key. Alerts without the field are sent as is.
+	GroupBy string
+	// Window is the time for collect the member alerts before the summary alert is sent. Default is 1 minute.
+	Window time.Duration
+	// AlertName returns the summary alert name for the group key. Default is "group-<key>".
+	AlertName func(key string) string
+	// AlertOptions are options for the summary alerts.
+	AlertOptions *AlertOptions
+}
+
+type alertGroupMember struct {
+	level   string
+	message string
+}
+
+type alertGroup struct {
+	failing     map[string]alertGroupMember
+	windowStart time.Time
+	// sent is the signature of the last sent summary alert
+	sent string
+}
+
+// AlertGrouper collects the alerts by the group key within the window and sends one summary alert per group.
+// The summary alert is resolved, when all group members are recovered.
+type AlertGrouper struct {
+	alert ModuleAlert
+	opts  AlertGroupOptions
+	now   func() time.Time
+
+	mu     sync.Mutex
+	groups map[string]*alertGroup
+	// flushMu serializes Flush calls, so the summary alert is not sent twice
+	flushMu sync.Mutex
+}
+
+// NewAlertGrouper creates a new AlertGrouper, which sends summary alerts with the alert module.
+func NewAlertGrouper(alert ModuleAlert, opts AlertGroupOptions) *AlertGrouper {
+	if opts.Window <= 0 {
+		opts.Window = defaultAlertGroupWindow
+	}
+	if opts.AlertName == nil {
+		opts.AlertName = func(key string) string {
+			return "group-" + key
+		}
+	}
+
+	return &AlertGrouper{
+		alert:  alert,
+		opts:   opts,
+		now:    time.Now,
+		groups: map[string]*alertGroup{},
+	}
+}
+
+// Success registers the member alert recovery.
+func (g *AlertGrouper) Success(alertName, message string, opts *AlertOptions) error {
+	return g.add("success", alertName, message, opts)
+}
+
+// Error registers the member alert with error level.
+func (g *AlertGrouper) Error(alertName, message string, opts *AlertOptions) error {
+	return g.add("error", alertName, message, opts)
+}
+
+// Warning registers the member alert with warning level.
+func (g *AlertGrouper) Warning(alertName, message string, opts *AlertOptions) error {
+	return g.add("warn", alertName, message, opts)
+}
+
+func (g *AlertGrouper) add(level, alertName, message string, opts *AlertOptions) error {
+	var key string
+	if opts != nil {
+		key = opts.Fields[g.opts.GroupBy]
+	}
+	if key == "" {
+		_, _, err := g.alert.call(level, alertName, message, opts)
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[key]
+	if !ok {
+		if level == "success" {
+			return nil
+		}
+		group = &alertGroup{failing: map[string]alertGroupMember{}}
+		g.groups[key] = group
+	}
+
+	if level == "success" {
+		delete(group.failing, alertName)
+		return nil
+	}
+
+	if len(group.failing) == 0 {
+		group.windowStart = g.now()
+	}
+	group.failing[alertName] = alertGroupMember{level: level, message: message}
+
+	return nil
+}
+
+// AlertGroupFlushError is returned by Flush, if some summary alerts are not sent.
+type AlertGroupFlushError struct {
+	// Errors are the send errors by the group key
+	Errors map[string]error
+}
+
+func (e *AlertGroupFlushError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, 0, len(keys))
+	for _, key := range keys {
+		messages = append(messages, fmt.Sprintf("failed to send summary alert for group %s: %v", key, e.Errors[key]))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any send error matches the target, so errors.Is works without the multiple errors unwrapping
+func (e *AlertGroupFlushError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// alertGroupSummary is the summary alert to send on Flush
+type alertGroupSummary struct {
+	key       string
+	group     *alertGroup
+	level     string
+	message   string
+	opts      *AlertOptions
+	signature string
+}
+
+// Flush sends the summary alerts for the groups with the elapsed window and resolves the recovered groups.
+// The summary alert is sent again only if the group members or the level are changed.
+// The alerts are sent without blocking the member alerts registration. The failed summary alerts
+// do not stop the others, they are sent again on the next Flush and reported with *AlertGroupFlushError.
+func (g *AlertGrouper) Flush() error {
+	g.flushMu.Lock()
+	defer g.flushMu.Unlock()
+
+	summaries := g.summaries()
+
+	errs := map[string]error{}
+	for _, s := range summaries {
+		if _, _, err := g.alert.call(s.level, g.opts.AlertName(s.key), s.message, s.opts); err != nil {
+			errs[s.key] = err
+			continue
+		}
+		g.sent(s)
+	}
+
+	if len(errs) > 0 {
+		return &AlertGroupFlushError{Errors: errs}
+	}
+
+	return nil
+}
+
+// summaries returns the summary alerts to send, sorted by the group key
+func (g *AlertGrouper) summaries() []alertGroupSummary {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+
+	keys := make([]string, 0, len(g.groups))
+	for key := range g.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var summaries []alertGroupSummary
+
+	for _, key := range keys {
+		group := g.groups[key]
+
+		if len(group.failing) == 0 {
+			if group.sent == "" {
+				delete(g.groups, key)
+				continue
+			}
+			summaries = append(summaries, alertGroupSummary{
+				key:     key,
+				group:   group,
+				level:   "success",
+				message: fmt.Sprintf("all alerts in group %s are recovered", key),
+				opts:    g.summaryOptions(key, nil),
+			})
+			continue
+		}
+
+		if now.Sub(group.windowStart) < g.opts.Window {
+			continue
+		}
+
+		members := make([]string, 0, len(group.failing))
+		level := "warn"
+		for name, m := range group.failing {
+			members = append(members, name)
+			if m.level == "error" {
+				level = "error"
+			}
+		}
+		sort.Strings(members)
+
+		signature := level + " " + strings.Join(members, " ")
+		if signature == group.sent {
+			continue
+		}
+
+		summaries = append(summaries, alertGroupSummary{
+			key:       key,
+			group:     group,
+			level:     level,
+			message:   fmt.Sprintf("%d alerts in group %s: %s", len(members), key, strings.Join(members, ", ")),
+			opts:      g.summaryOptions(key, members),
+			signature: signature,
+		})
+	}
+
+	return summaries
+}
+
+// sent records the sent summary alert. The group may be changed, while the alert is sent.
+func (g *AlertGrouper) sent(s alertGroupSummary) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.groups[s.key] != s.group {
+		return
+	}
+
+	s.group.sent = s.signature
+	if s.signature == "" && len(s.group.failing) == 0 {
+		delete(g.groups, s.key)
+	}
+}
+
+// Run flushes the groups with the interval until the context is done. The interval <= 0 means 10 seconds.
+// Flush errors are passed to the onError callback, if it is not nil.
+func (g *AlertGrouper) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultAlertGroupFlushInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := g.Flush(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (g *AlertGrouper) summaryOptions(key string, members []string) *AlertOptions {
+	o := AlertOptions{}
+	if g.opts.AlertOptions != nil {
+		o = *g.opts.AlertOptions
+	}
+
+	o.Fields = map[string]string{}
+	if g.opts.AlertOptions != nil {
+		for k, v := range g.opts.AlertOptions.Fields {
+			o.Fields[k] = v
+		}
+	}
+	o.Fields[g.opts.GroupBy] = key
+	o.Fields["count"] = strconv.Itoa(len(members))
+	if len(members) > 0 {
+		o.Fields["members"] = strings.Join(members, " ")
+	}
+
+	return &o
+}
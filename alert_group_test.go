@@ -0,0 +1,219 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertGrouper(t *testing.T) {
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path+" "+string(body))
+			return []byte(`{"level_was_updated":true,"alert":{"name":"x"}}`), nil
+		},
+	}
+
+	now := time.Unix(1000, 0)
+
+	g := NewAlertGrouper(m, AlertGroupOptions{GroupBy: "dep"})
+	g.now = func() time.Time { return now }
+
+	dep := &AlertOptions{Fields: map[string]string{"dep": "db"}}
+
+	if err := g.Error("h1", "m1", dep); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := g.Warning("h2", "m2", dep); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	// the alert without the group field is sent as is
+	if err := g.Error("other", "m3", nil); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	// the window is not elapsed
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "alert/error/other m3" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+
+	now = now.Add(time.Minute)
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 2 || calls[1] != "alert/error/group-db?fields=count%3A2%2Cdep%3Adb%2Cmembers%3Ah1+h2 2 alerts in group db: h1, h2" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+
+	// nothing is changed, so the summary is not sent again
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+
+	if err := g.Success("h1", "ok", dep); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 3 || calls[2] != "alert/warn/group-db?fields=count%3A1%2Cdep%3Adb%2Cmembers%3Ah2 1 alerts in group db: h2" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+
+	if err := g.Success("h2", "ok", dep); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 4 || calls[3] != "alert/success/group-db?fields=count%3A0%2Cdep%3Adb all alerts in group db are recovered" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+	if len(g.groups) != 0 {
+		t.Fatalf("expected removed group, got %v", g.groups)
+	}
+
+	// the success for the unknown group is ignored
+	if err := g.Success("h3", "ok", dep); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := g.Flush(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+}
+
+func TestAlertGrouper_Flush_error(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			return nil, fmt.Errorf("err1")
+		},
+	}
+
+	g := NewAlertGrouper(m, AlertGroupOptions{
+		GroupBy:   "dep",
+		Window:    time.Nanosecond,
+		AlertName: func(key string) string { return "summary-" + key },
+	})
+
+	if err := g.Error("h1", "m1", &AlertOptions{Fields: map[string]string{"dep": "db"}}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	err := g.Flush()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to send summary alert for group db: failed to call alert/error/summary-db?fields=count%3A1%2Cdep%3Adb%2Cmembers%3Ah1: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestAlertGrouper_Flush_partial_error(t *testing.T) {
+	var g *AlertGrouper
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path)
+			if strings.HasPrefix(path, "alert/error/summary-a?") {
+				return nil, fmt.Errorf("%w: err1", ErrNotSupported)
+			}
+			// the members are registered, while the summary alert is sent
+			if err := g.Error("h3", "m3", &AlertOptions{Fields: map[string]string{"dep": "c"}}); err != nil {
+				t.Fatalf("unexpected error, got %v", err)
+			}
+			return []byte(`{"level_was_updated":true,"alert":{"name":"x"}}`), nil
+		},
+	}
+
+	g = NewAlertGrouper(m, AlertGroupOptions{
+		GroupBy:   "dep",
+		Window:    time.Nanosecond,
+		AlertName: func(key string) string { return "summary-" + key },
+	})
+
+	for name, key := range map[string]string{"h1": "a", "h2": "b"} {
+		if err := g.Error(name, "m", &AlertOptions{Fields: map[string]string{"dep": key}}); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	err := g.Flush()
+	var flushErr *AlertGroupFlushError
+	if !errors.As(err, &flushErr) {
+		t.Fatalf("expected AlertGroupFlushError, got %v", err)
+	}
+	if len(flushErr.Errors) != 1 || flushErr.Errors["a"] == nil {
+		t.Fatalf("unexpected errors value, got %v", flushErr.Errors)
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+
+	// the failed summary alert is sent again, the sent one is not
+	calls = nil
+	time.Sleep(time.Millisecond)
+	if err := g.Flush(); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	expect := []string{
+		"alert/error/summary-a?fields=count%3A1%2Cdep%3Aa%2Cmembers%3Ah1",
+		"alert/error/summary-c?fields=count%3A1%2Cdep%3Ac%2Cmembers%3Ah3",
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+}
+
+func TestAlertGrouper_Run(t *testing.T) {
+	called := make(chan struct{}, 1)
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			select {
+			case called <- struct{}{}:
+			default:
+			}
+			return []byte(`{"alert":{"name":"x"}}`), nil
+		},
+	}
+
+	g := NewAlertGrouper(m, AlertGroupOptions{GroupBy: "dep", Window: time.Nanosecond})
+	if err := g.Error("h1", "m1", &AlertOptions{Fields: map[string]string{"dep": "db"}}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go g.Run(ctx, time.Millisecond, nil)
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatalf("summary alert was not sent")
+	}
+
+	// the default interval is used
+	cancel()
+	g.Run(ctx, 0, nil)
+}
@@ -0,0 +1,271 @@
+package coreapi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// AlertTemplate defines the alert message and fields as text/template sources.
+type AlertTemplate struct {
+	Message string
+	Fields  map[string]string
+}
+
+// AlertTemplateData is the data passed to the alert templates.
+type AlertTemplateData struct {
+	// Name is the alert name
+	Name string
+	// Level is the alert level: success, warn or error
+	Level string
+	// Alert is the current alert state. It is nil, if the alert does not exist yet or its lookup is failed,
+	// so use it in the templates with the check, e.g. "{{ with .Alert }}{{ .Count }}{{ end }}".
+	Alert *Alert
+	// Values are the check values
+	Values interface{}
+	// Fields are the fields from the alert options
+	Fields map[string]string
+}
+
+type compiledAlertTemplate struct {
+	message *template.Template
+	fields  map[string]*template.Template
+}
+
+// AlertTemplates renders alert messages and fields from the named templates, registered per alert name.
+type AlertTemplates struct {
+	alert ModuleAlert
+
+	mu        sync.RWMutex
+	templates map[string]*compiledAlertTemplate
+}
+
+// NewAlertTemplates creates a new AlertTemplates instance, which sends alerts with the alert module.
+func NewAlertTemplates(alert ModuleAlert) *AlertTemplates {
+	return &AlertTemplates{
+		alert:     alert,
+		templates: map[string]*compiledAlertTemplate{},
+	}
+}
+
+// Register parses the template and registers it for the alert name. The existing template is replaced.
+func (t *AlertTemplates) Register(alertName string, tpl AlertTemplate) error {
+	c := &compiledAlertTemplate{
+		fields: map[string]*template.Template{},
+	}
+
+	var err error
+
+	c.message, err = template.New(alertName).Funcs(alertTemplateFuncs).Option("missingkey=error").Parse(tpl.Message)
+	if err != nil {
+		return fmt.Errorf("failed to parse message template for alert %s: %w", alertName, err)
+	}
+
+	for k, v := range tpl.Fields {
+		c.fields[k], err = template.New(alertName + "." + k).Funcs(alertTemplateFuncs).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse field %s template for alert %s: %w", k, alertName, err)
+		}
+	}
+
+	t.mu.Lock()
+	t.templates[alertName] = c
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Render renders the message and the fields for the alert data.Name.
+func (t *AlertTemplates) Render(data AlertTemplateData) (string, map[string]string, error) {
+	t.mu.RLock()
+	c, ok := t.templates[data.Name]
+	t.mu.RUnlock()
+
+	if !ok {
+		return "", nil, fmt.Errorf("template for alert %s is not registered", data.Name)
+	}
+
+	buf := &strings.Builder{}
+
+	if err := c.message.Execute(buf, data); err != nil {
+		return "", nil, fmt.Errorf("failed to render message for alert %s: %w", data.Name, err)
+	}
+
+	message := buf.String()
+
+	var fields map[string]string
+	if len(c.fields) > 0 {
+		fields = make(map[string]string, len(c.fields))
+	}
+	for k, tpl := range c.fields {
+		buf.Reset()
+		if err := tpl.Execute(buf, data); err != nil {
+			return "", nil, fmt.Errorf("failed to render field %s for alert %s: %w", k, data.Name, err)
+		}
+		fields[k] = buf.String()
+	}
+
+	return message, fields, nil
+}
+
+// Success renders the alert template and calls alert module with success level.
+func (t *AlertTemplates) Success(alertName string, values interface{}, opts *AlertOptions) (*Alert, bool, error) {
+	return t.call("success", alertName, values, opts)
+}
+
+// Error renders the alert template and calls alert module with error level.
+func (t *AlertTemplates) Error(alertName string, values interface{}, opts *AlertOptions) (*Alert, bool, error) {
+	return t.call("error", alertName, values, opts)
+}
+
+// Warning renders the alert template and calls alert module with warning level.
+func (t *AlertTemplates) Warning(alertName string, values interface{}, opts *AlertOptions) (*Alert, bool, error) {
+	return t.call("warn", alertName, values, opts)
+}
+
+func (t *AlertTemplates) call(level, alertName string, values interface{}, opts *AlertOptions) (*Alert, bool, error) {
+	// the failed lookup does not fail the alert, the alert state is nil as for the new alert
+	current, _ := t.alert.Get(alertName)
+
+	data := AlertTemplateData{
+		Name:   alertName,
+		Level:  level,
+		Alert:  current,
+		Values: values,
+	}
+	if opts != nil {
+		data.Fields = opts.Fields
+	}
+
+	message, fields, err := t.Render(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(fields) > 0 {
+		o := AlertOptions{}
+		if opts != nil {
+			o = *opts
+		}
+		o.Fields = make(map[string]string, len(data.Fields)+len(fields))
+		for k, v := range data.Fields {
+			o.Fields[k] = v
+		}
+		for k, v := range fields {
+			o.Fields[k] = v
+		}
+		opts = &o
+	}
+
+	return t.alert.call(level, alertName, message, opts)
+}
+
+var alertTemplateFuncs = template.FuncMap{
+	"humanize":         humanizeNumber,
+	"humanizeDuration": humanizeDuration,
+}
+
+// humanizeNumber formats the number with the metric prefix, e.g. 1234567 -> 1.235M
+func humanizeNumber(v interface{}) (string, error) {
+	f, err := toFloat64(v)
+	if err != nil {
+		return "", err
+	}
+
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', 4, 64), nil
+	}
+
+	prefixes := []string{"", "k", "M", "G", "T", "P", "E"}
+	i := 0
+	for math.Abs(f) >= 1000 && i < len(prefixes)-1 {
+		f /= 1000
+		i++
+	}
+
+	return strconv.FormatFloat(f, 'g', 4, 64) + prefixes[i], nil
+}
+
+// humanizeDuration formats the time.Duration or the number of seconds, e.g. 93784 -> 1d 2h 3m 4s
+func humanizeDuration(v interface{}) (string, error) {
+	var d time.Duration
+
+	if dd, ok := v.(time.Duration); ok {
+		d = dd
+	} else {
+		f, err := toFloat64(v)
+		if err != nil {
+			return "", err
+		}
+		d = time.Duration(f * float64(time.Second))
+	}
+
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	if d < time.Second {
+		return sign + d.Round(time.Millisecond).String(), nil
+	}
+
+	var parts []string
+	units := []struct {
+		d      time.Duration
+		suffix string
+	}{
+		{d: time.Hour * 24, suffix: "d"},
+		{d: time.Hour, suffix: "h"},
+		{d: time.Minute, suffix: "m"},
+		{d: time.Second, suffix: "s"},
+	}
+	for _, u := range units {
+		if n := d / u.d; n > 0 {
+			parts = append(parts, strconv.FormatInt(int64(n), 10)+u.suffix)
+			d -= n * u.d
+		}
+	}
+
+	return sign + strings.Join(parts, " "), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int:
+		return float64(x), nil
+	case int8:
+		return float64(x), nil
+	case int16:
+		return float64(x), nil
+	case int32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint:
+		return float64(x), nil
+	case uint8:
+		return float64(x), nil
+	case uint16:
+		return float64(x), nil
+	case uint32:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to convert %q to number: %w", x, err)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("unsupported number type %T", v)
+}
@@ -0,0 +1,215 @@
+package coreapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAlertTemplates_Register_error(t *testing.T) {
+	tpl := NewAlertTemplates(ModuleAlert{})
+
+	err := tpl.Register("a", AlertTemplate{Message: "{{ .Name "})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to parse message template for alert a: template: a:1: unclosed action" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	err = tpl.Register("a", AlertTemplate{Message: "ok", Fields: map[string]string{"f": "{{ .Foo"}})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to parse field f template for alert a: template: a.f:1: unclosed action" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestAlertTemplates_Render_not_registered(t *testing.T) {
+	tpl := NewAlertTemplates(ModuleAlert{})
+
+	_, _, err := tpl.Render(AlertTemplateData{Name: "a"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "template for alert a is not registered" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestAlertTemplates_Render(t *testing.T) {
+	tpl := NewAlertTemplates(ModuleAlert{})
+
+	err := tpl.Register("rps", AlertTemplate{
+		Message: `{{ .Level }}: service {{ .Values.Name }} rps {{ humanize .Values.RPS }}{{ with .Alert }} for {{ humanizeDuration .Count }}{{ end }}`,
+		Fields: map[string]string{
+			"host": "{{ .Fields.host }}",
+			"rps":  "{{ .Values.RPS }}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	message, fields, err := tpl.Render(AlertTemplateData{
+		Name:  "rps",
+		Level: "error",
+		Alert: &Alert{Count: 90},
+		Values: struct {
+			Name string
+			RPS  int
+		}{Name: "cache", RPS: 12345},
+		Fields: map[string]string{"host": "h1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if message != "error: service cache rps 12.35k for 1m 30s" {
+		t.Fatalf("unexpected message value, got %s", message)
+	}
+	if len(fields) != 2 || fields["host"] != "h1" || fields["rps"] != "12345" {
+		t.Fatalf("unexpected fields value, got %v", fields)
+	}
+}
+
+func TestAlertTemplates_Error(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			switch path {
+			case "alert/get/a":
+				return []byte(fmt.Sprintf(`{"name":"a","count":3,"start":%q}`, start.Format(time.RFC3339Nano))), nil
+			case "alert/error/a?fields=host%3Ah1%2Cvalue%3A42&quiet=true":
+				if string(body) != "value 42, count 3" {
+					t.Fatalf("unexpected body value, got %s", string(body))
+				}
+				return []byte(`{"level_was_updated":true,"alert":{"name":"a","count":4}}`), nil
+			}
+			t.Fatalf("unexpected path value, got %s", path)
+			return nil, nil
+		},
+	}
+
+	tpl := NewAlertTemplates(m)
+
+	err := tpl.Register("a", AlertTemplate{
+		Message: "value {{ .Values }}, count {{ .Alert.Count }}",
+		Fields:  map[string]string{"value": "{{ .Values }}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	opts := &AlertOptions{Quiet: true, Fields: map[string]string{"host": "h1"}}
+
+	a, updated, err := tpl.Error("a", 42, opts)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !updated {
+		t.Fatalf("expected updated value, got false")
+	}
+	if a.Count != 4 {
+		t.Fatalf("unexpected count value, got %d", a.Count)
+	}
+	if len(opts.Fields) != 1 {
+		t.Fatalf("options must not be changed, got %v", opts.Fields)
+	}
+}
+
+func TestAlertTemplates_Success_error_get(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			switch path {
+			case "alert/get/a":
+				return nil, fmt.Errorf("err1")
+			case "alert/success/a":
+				if string(body) != "recovered, no state" {
+					t.Fatalf("unexpected body value, got %s", string(body))
+				}
+				return []byte(`{"level_was_updated":true,"alert":{"name":"a","level":1}}`), nil
+			}
+			t.Fatalf("unexpected path value, got %s", path)
+			return nil, nil
+		},
+	}
+
+	tpl := NewAlertTemplates(m)
+
+	err := tpl.Register("a", AlertTemplate{
+		Message: "recovered, {{ with .Alert }}count {{ .Count }}{{ else }}no state{{ end }}",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, updated, err := tpl.Success("a", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !updated {
+		t.Fatalf("expected updated value, got false")
+	}
+}
+
+func TestHumanizeNumber(t *testing.T) {
+	for v, expect := range map[interface{}]string{
+		0:            "0",
+		12:           "12",
+		1.5:          "1.5",
+		1234:         "1.234k",
+		int64(-5e6):  "-5M",
+		"2500000000": "2.5G",
+	} {
+		s, err := humanizeNumber(v)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if s != expect {
+			t.Fatalf("unexpected value for %v, got %s, expect %s", v, s, expect)
+		}
+	}
+
+	_, err := humanizeNumber("x")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	for v, expect := range map[interface{}]string{
+		0:                                   "0s",
+		0.25:                                "250ms",
+		93784:                               "1d 2h 3m 4s",
+		time.Hour:                           "1h",
+		-time.Minute - time.Second:          "-1m 1s",
+		time.Hour*48 + time.Millisecond*500: "2d",
+	} {
+		s, err := humanizeDuration(v)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if s != expect {
+			t.Fatalf("unexpected value for %v, got %s, expect %s", v, s, expect)
+		}
+	}
+
+	_, err := humanizeDuration(struct{}{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestAlert_Duration(t *testing.T) {
+	var a *Alert
+	if a.Duration() != 0 {
+		t.Fatalf("unexpected duration for nil alert, got %s", a.Duration())
+	}
+
+	a = &Alert{Start: time.Now().Add(-time.Hour)}
+	if d := a.Duration(); d < time.Hour || d > time.Hour+time.Minute {
+		t.Fatalf("unexpected duration value, got %s", d)
+	}
+}
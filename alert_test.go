@@ -1,6 +1,7 @@
 package coreapi
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -195,3 +196,148 @@ func TestModuleAlert_Get(t *testing.T) {
 		t.Fatalf("unexpected count value, got %d, expect 5", a.Count)
 	}
 }
+
+func TestImageDataURI(t *testing.T) {
+	uri := ImageDataURI([]byte("\x89PNG\x0D\x0A\x1A\x0A"))
+	if uri != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Fatalf("unexpected uri value, got %s", uri)
+	}
+}
+
+func TestModuleAlert_call_image_data(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			if path != "alert/error/a?image=data%3Aimage%2Fpng%3Bbase64%2CiVBORw0KGgo%3D" {
+				t.Fatalf("unexpected path value, got %s", path)
+			}
+			return []byte(`{"level_was_updated":true,"alert":{"name":"a"}}`), nil
+		},
+	}
+
+	_, _, err := m.Error("a", "b", &AlertOptions{ImageData: []byte("\x89PNG\x0D\x0A\x1A\x0A")})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestModuleAlert_call_chart(t *testing.T) {
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path)
+			if path == "chart/render" {
+				if string(body) != `{"title":"t","series":[{"Color":"red","LineColor":"","PointColor":"","Data":[{"Timestamp":1,"Value":2}]}]}` {
+					t.Fatalf("unexpected body value, got %s", string(body))
+				}
+				return []byte(`"iVBORw0KGgo="`), nil
+			}
+			if path != "alert/error/a?image=data%3Aimage%2Fpng%3Bbase64%2CiVBORw0KGgo%3D" {
+				t.Fatalf("unexpected path value, got %s", path)
+			}
+			return []byte(`{"level_was_updated":true,"alert":{"name":"a"}}`), nil
+		},
+	}
+
+	_, _, err := m.Error("a", "b", &AlertOptions{Chart: &AlertChart{
+		Title:  "t",
+		Series: []DataSeries{{Color: "red", Data: []DataItem{{Timestamp: 1, Value: 2}}}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("unexpected calls count, got %d", len(calls))
+	}
+}
+
+func TestModuleAlert_call_chart_error(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			if path != "chart/render" {
+				t.Fatalf("unexpected path value, got %s", path)
+			}
+			return nil, fmt.Errorf("err1")
+		},
+	}
+
+	_, _, err := m.Error("a", "b", &AlertOptions{Chart: &AlertChart{Title: "t"}})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to render alert chart: failed to call chart/render: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleAlert_call_image_conflict(t *testing.T) {
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			t.Fatalf("unexpected call %s", path)
+			return nil, nil
+		},
+	}
+
+	_, _, err := m.Error("a", "b", &AlertOptions{Image: "image.png", ImageData: []byte("x")})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "only one of Image, ImageData and Chart options may be defined" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleAlert_call_url_too_long(t *testing.T) {
+	var calls int
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls++
+			return []byte(`{"level_was_updated":true,"alert":{"name":"a"}}`), nil
+		},
+	}
+
+	img := make([]byte, DefaultMaxAlertURLLength)
+
+	_, _, err := m.Error("a", "b", &AlertOptions{ImageData: img})
+	if !errors.Is(err, ErrAlertURLTooLong) {
+		t.Fatalf("expected ErrAlertURLTooLong, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	_, _, err = m.WithMaxURLLength(DefaultMaxAlertURLLength*2).Error("a", "b", &AlertOptions{ImageData: img})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, _, err = m.WithMaxURLLength(20).Error("a", "b", &AlertOptions{ImageData: []byte("x")})
+	if err == nil || err.Error() != "alert request path is too long: 78 bytes, the limit is 20 bytes, use the smaller image or the Image URL" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+
+	// the limit is applied only to the attached image
+	_, _, err = m.WithMaxURLLength(20).Error("a", "b", &AlertOptions{
+		Image:  "https://example.com/chart.png",
+		Fields: map[string]string{"host": "a.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	// the chart is not rendered, if the request path is too long without the image
+	_, _, err = m.WithMaxURLLength(20).Error("a", "b", &AlertOptions{
+		Fields: map[string]string{"host": "a.example.com"},
+		Chart:  &AlertChart{Title: "c", Series: []DataSeries{{Data: []DataItem{{Timestamp: 1, Value: 1}}}}},
+	})
+	if !errors.Is(err, ErrAlertURLTooLong) {
+		t.Fatalf("expected ErrAlertURLTooLong, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+}
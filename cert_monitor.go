@@ -0,0 +1,179 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Certificate monitor defaults
+const (
+	DefaultCertWarning = 14 * 24 * time.Hour
+	DefaultCertError   = 3 * 24 * time.Hour
+)
+
+const defaultCertCheckInterval = time.Hour
+
+// CertMonitorOptions are options for the CertMonitor. Zero values mean the defaults.
+type CertMonitorOptions struct {
+	// Warning is the time left to the expiry, when the warning alert is sent. Default is 14 days.
+	Warning time.Duration
+	// Error is the time left to the expiry, when the error alert is sent. Default is 3 days.
+	Error time.Duration
+	// AlertName returns the alert name for the host. Default is "tls-<host>".
+	AlertName func(host string) string
+	// AlertOptions are options for the alerts. The host, expiry and days_left fields are added to the alert fields.
+	AlertOptions *AlertOptions
+}
+
+// CertStatus is the certificate check result of the host
+type CertStatus struct {
+	Host string
+	// Level is the sent alert level, one of AlertLevelSuccess, AlertLevelWarning or AlertLevelError
+	Level string
+	// Expiry is the earliest expiry of the host certificates. It is zero, if the check is failed.
+	Expiry time.Time
+	// TimeLeft is the time left to the Expiry
+	TimeLeft time.Duration
+	// Error is the check or the alert error
+	Error error
+}
+
+// CertMonitor checks the TLS certificates of the hosts and sends the alerts by the time left to the expiry.
+// The host without certificates or with the failed check is reported with the error alert.
+type CertMonitor struct {
+	tls   ModuleTLS
+	alert ModuleAlert
+	hosts []string
+	opts  CertMonitorOptions
+	now   func() time.Time
+}
+
+// NewCertMonitor creates the certificate monitor for the hosts.
+func NewCertMonitor(tls ModuleTLS, alert ModuleAlert, hosts []string, opts CertMonitorOptions) (*CertMonitor, error) {
+	if opts.Warning <= 0 {
+		opts.Warning = DefaultCertWarning
+	}
+	if opts.Error <= 0 {
+		opts.Error = DefaultCertError
+	}
+	if opts.Error > opts.Warning {
+		return nil, fmt.Errorf("certificate error threshold must not be greater than warning threshold")
+	}
+	if opts.AlertName == nil {
+		opts.AlertName = func(host string) string {
+			return "tls-" + host
+		}
+	}
+
+	return &CertMonitor{
+		tls:   tls,
+		alert: alert,
+		hosts: append([]string(nil), hosts...),
+		opts:  opts,
+		now:   time.Now,
+	}, nil
+}
+
+// Check checks all hosts and sends the alerts. The statuses have the same order as the hosts.
+// The per-host errors are returned in the statuses.
+func (m *CertMonitor) Check() []CertStatus {
+	result := make([]CertStatus, 0, len(m.hosts))
+	for _, host := range m.hosts {
+		result = append(result, m.check(host))
+	}
+	return result
+}
+
+// Run checks the hosts every interval, until the context is done. The interval <= 0 means 1 hour.
+// onError is called for every host error, it may be nil.
+func (m *CertMonitor) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultCertCheckInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		for _, s := range m.Check() {
+			if s.Error != nil && onError != nil {
+				onError(fmt.Errorf("host %s: %w", s.Host, s.Error))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (m *CertMonitor) check(host string) CertStatus {
+	s := CertStatus{Host: host}
+
+	results, err := m.tls.Get(host)
+	if err == nil && len(results) == 0 {
+		err = fmt.Errorf("no certificates")
+	}
+	if err != nil {
+		s.Level = AlertLevelError
+		s.Error = err
+		if errAlert := m.send(s, fmt.Sprintf("failed to check the certificate of %s: %v", host, err)); errAlert != nil {
+			s.Error = fmt.Errorf("%v; failed to send alert: %w", err, errAlert)
+		}
+		return s
+	}
+
+	expiry := results[0].Expiry
+	for _, r := range results[1:] {
+		if r.Expiry < expiry {
+			expiry = r.Expiry
+		}
+	}
+	s.Expiry = time.Unix(expiry, 0)
+	s.TimeLeft = s.Expiry.Sub(m.now())
+
+	var message string
+	left, _ := humanizeDuration(s.TimeLeft.Truncate(time.Minute))
+	switch {
+	case s.TimeLeft <= 0:
+		s.Level = AlertLevelError
+		message = fmt.Sprintf("the certificate of %s is expired at %s", host, s.Expiry.UTC().Format(time.RFC3339))
+	case s.TimeLeft <= m.opts.Error:
+		s.Level = AlertLevelError
+		message = fmt.Sprintf("the certificate of %s expires in %s", host, left)
+	case s.TimeLeft <= m.opts.Warning:
+		s.Level = AlertLevelWarning
+		message = fmt.Sprintf("the certificate of %s expires in %s", host, left)
+	default:
+		s.Level = AlertLevelSuccess
+		message = fmt.Sprintf("the certificate of %s is valid for %s", host, left)
+	}
+
+	s.Error = m.send(s, message)
+	return s
+}
+
+func (m *CertMonitor) send(s CertStatus, message string) error {
+	o := AlertOptions{}
+	if m.opts.AlertOptions != nil {
+		o = *m.opts.AlertOptions
+	}
+	o.Fields = map[string]string{}
+	if m.opts.AlertOptions != nil {
+		for k, v := range m.opts.AlertOptions.Fields {
+			o.Fields[k] = v
+		}
+	}
+	o.Fields["host"] = s.Host
+	if !s.Expiry.IsZero() {
+		o.Fields["expiry"] = s.Expiry.UTC().Format(time.RFC3339)
+		o.Fields["days_left"] = strconv.Itoa(int(s.TimeLeft / (24 * time.Hour)))
+	}
+
+	_, _, err := m.alert.call(s.Level, m.opts.AlertName(s.Host), message, &o)
+	return err
+}
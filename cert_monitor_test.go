@@ -0,0 +1,137 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCertMonitor_Check(t *testing.T) {
+	now := time.Unix(1660557600, 0)
+	day := int64(24 * 60 * 60)
+
+	var calls []string
+	rf := func(path, contentType string, body []byte) ([]byte, error) {
+		if path == "tls/get" {
+			switch string(body) {
+			case "ok.com":
+				return []byte(fmt.Sprintf(`[{"expiry":%d},{"expiry":%d}]`, now.Unix()+100*day, now.Unix()+30*day)), nil
+			case "warn.com":
+				return []byte(fmt.Sprintf(`[{"expiry":%d}]`, now.Unix()+10*day+3600)), nil
+			case "error.com":
+				return []byte(fmt.Sprintf(`[{"expiry":%d}]`, now.Unix()+2*day)), nil
+			case "expired.com":
+				return []byte(fmt.Sprintf(`[{"expiry":%d}]`, now.Unix()-day)), nil
+			case "empty.com":
+				return []byte(`[]`), nil
+			}
+			return nil, fmt.Errorf("err1")
+		}
+		calls = append(calls, path+" "+string(body))
+		return []byte(`{"level_was_updated":true,"alert":{"name":"x"}}`), nil
+	}
+
+	m, err := NewCertMonitor(ModuleTLS{rf: rf}, ModuleAlert{rf: rf},
+		[]string{"ok.com", "warn.com", "error.com", "expired.com", "empty.com", "bad.com"},
+		CertMonitorOptions{AlertOptions: &AlertOptions{Fields: map[string]string{"team": "a"}}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	m.now = func() time.Time { return now }
+
+	statuses := m.Check()
+
+	var levels []string
+	for _, s := range statuses {
+		levels = append(levels, s.Host+":"+s.Level)
+	}
+	if strings.Join(levels, " ") != "ok.com:success warn.com:warn error.com:error expired.com:error empty.com:error bad.com:error" {
+		t.Fatalf("unexpected levels, got %v", levels)
+	}
+	if statuses[0].TimeLeft != 30*24*time.Hour || statuses[0].Expiry.Unix() != now.Unix()+30*day {
+		t.Fatalf("unexpected status, got %+v", statuses[0])
+	}
+	if statuses[4].Error == nil || statuses[4].Error.Error() != "no certificates" {
+		t.Fatalf("unexpected error value, got %v", statuses[4].Error)
+	}
+	if statuses[5].Error == nil || statuses[5].Error.Error() != "failed to get tls info: err1" {
+		t.Fatalf("unexpected error value, got %v", statuses[5].Error)
+	}
+
+	expect := []string{
+		"alert/success/tls-ok.com?fields=days_left%3A30%2Cexpiry%3A2022-09-14T10%3A00%3A00Z%2Chost%3Aok.com%2Cteam%3Aa the certificate of ok.com is valid for 30d",
+		"alert/warn/tls-warn.com?fields=days_left%3A10%2Cexpiry%3A2022-08-25T11%3A00%3A00Z%2Chost%3Awarn.com%2Cteam%3Aa the certificate of warn.com expires in 10d 1h",
+		"alert/error/tls-error.com?fields=days_left%3A2%2Cexpiry%3A2022-08-17T10%3A00%3A00Z%2Chost%3Aerror.com%2Cteam%3Aa the certificate of error.com expires in 2d",
+		"alert/error/tls-expired.com?fields=days_left%3A-1%2Cexpiry%3A2022-08-14T10%3A00%3A00Z%2Chost%3Aexpired.com%2Cteam%3Aa the certificate of expired.com is expired at 2022-08-14T10:00:00Z",
+		"alert/error/tls-empty.com?fields=host%3Aempty.com%2Cteam%3Aa failed to check the certificate of empty.com: no certificates",
+		"alert/error/tls-bad.com?fields=host%3Abad.com%2Cteam%3Aa failed to check the certificate of bad.com: failed to get tls info: err1",
+	}
+	if strings.Join(calls, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("unexpected calls, got\n%s", strings.Join(calls, "\n"))
+	}
+}
+
+func TestCertMonitor_alert_error(t *testing.T) {
+	rf := func(path, contentType string, body []byte) ([]byte, error) {
+		if path == "tls/get" {
+			return nil, fmt.Errorf("err1")
+		}
+		return nil, fmt.Errorf("err2")
+	}
+
+	m, _ := NewCertMonitor(ModuleTLS{rf: rf}, ModuleAlert{rf: rf}, []string{"a.com"}, CertMonitorOptions{
+		AlertName: func(host string) string { return "cert " + host },
+	})
+
+	s := m.Check()
+	if s[0].Error == nil || s[0].Error.Error() != "failed to get tls info: err1; failed to send alert: failed to call alert/error/cert%20a.com?fields=host%3Aa.com: err2" {
+		t.Fatalf("unexpected error value, got %v", s[0].Error)
+	}
+}
+
+func TestNewCertMonitor_error(t *testing.T) {
+	_, err := NewCertMonitor(ModuleTLS{}, ModuleAlert{}, nil, CertMonitorOptions{Warning: time.Hour, Error: 2 * time.Hour})
+	if err == nil || err.Error() != "certificate error threshold must not be greater than warning threshold" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestCertMonitor_Run(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	rf := func(path, contentType string, body []byte) ([]byte, error) {
+		if path == "tls/get" {
+			return nil, fmt.Errorf("err1")
+		}
+		return []byte(`{"level_was_updated":true,"alert":{"name":"x"}}`), nil
+	}
+
+	m, _ := NewCertMonitor(ModuleTLS{rf: rf}, ModuleAlert{rf: rf}, []string{"a.com"}, CertMonitorOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, time.Millisecond, func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			cancel()
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("run is not stopped")
+	}
+	if len(errs) == 0 || errs[0].Error() != "host a.com: failed to get tls info: err1" {
+		t.Fatalf("unexpected errors, got %v", errs)
+	}
+
+	// the default interval is used
+	m.Run(ctx, 0, nil)
+}
@@ -12,25 +12,139 @@ type DataItem struct {
 	Value     float64
 }
 
+// Line styles
+const (
+	LineStyleSolid  = "solid"
+	LineStyleDashed = "dashed"
+	LineStyleDotted = "dotted"
+)
+
 // DataSeries represents data series
 type DataSeries struct {
 	Color      string
 	LineColor  string
 	PointColor string
 	Data       []DataItem
+	// Name is the series name in the legend
+	Name string `json:",omitempty"`
+	// LineStyle is one of LineStyleSolid, LineStyleDashed or LineStyleDotted
+	LineStyle string `json:",omitempty"`
+	// LineWidth is the line width in pixels
+	LineWidth float64 `json:",omitempty"`
+	// Thresholds, Bands and Markers annotate the chart in the series color by default
+	Thresholds []ChartThreshold `json:",omitempty"`
+	Bands      []ChartBand      `json:",omitempty"`
+	Markers    []ChartMarker    `json:",omitempty"`
+}
+
+// Chart size limits in pixels
+const (
+	// MinChartSize is the minimal chart width and height, which fits the margins and the minimal plot area
+	MinChartSize = 100
+	// MaxChartSize is the maximal chart width and height
+	MaxChartSize = 4096
+	// MaxChartLineWidth is the maximal series line width
+	MaxChartLineWidth = 32
+)
+
+// Chart formats
+const (
+	ChartFormatPNG = "png"
+	ChartFormatSVG = "svg"
+)
+
+// Legend positions
+const (
+	LegendNone   = "none"
+	LegendTop    = "top"
+	LegendBottom = "bottom"
+	LegendRight  = "right"
+)
+
+// ChartOptions are options for the chart rendering. Zero values mean the balerter defaults.
+type ChartOptions struct {
+	// Width and Height are the image size in pixels
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// XLabel and YLabel are the axes labels
+	XLabel string `json:"x_label,omitempty"`
+	YLabel string `json:"y_label,omitempty"`
+	// Unit is appended to the Y axis values
+	Unit string `json:"unit,omitempty"`
+	// YMin and YMax fix the Y axis range
+	YMin *float64 `json:"y_min,omitempty"`
+	YMax *float64 `json:"y_max,omitempty"`
+	// Legend is one of LegendNone, LegendTop, LegendBottom or LegendRight
+	Legend string `json:"legend,omitempty"`
+	// Format is one of ChartFormatPNG or ChartFormatSVG
+	Format string `json:"format,omitempty"`
+	// Thresholds, Bands and Markers annotate the chart
+	Thresholds []ChartThreshold `json:"thresholds,omitempty"`
+	Bands      []ChartBand      `json:"bands,omitempty"`
+	Markers    []ChartMarker    `json:"markers,omitempty"`
+	// Local renders the chart in the library with RenderChart instead of the balerter
+	Local bool `json:"-"`
+}
+
+// Validate checks the chart options.
+func (o *ChartOptions) Validate() error {
+	if o.Width < 0 || o.Height < 0 {
+		return fmt.Errorf("chart size must not be negative")
+	}
+	if o.Width > MaxChartSize || o.Height > MaxChartSize {
+		return fmt.Errorf("chart size must not exceed %d", MaxChartSize)
+	}
+	if (o.Width != 0 && o.Width < MinChartSize) || (o.Height != 0 && o.Height < MinChartSize) {
+		return fmt.Errorf("chart size must be at least %d", MinChartSize)
+	}
+	if o.YMin != nil && o.YMax != nil && *o.YMin >= *o.YMax {
+		return fmt.Errorf("chart y min must be less than y max")
+	}
+	switch o.Legend {
+	case "", LegendNone, LegendTop, LegendBottom, LegendRight:
+	default:
+		return fmt.Errorf("unknown chart legend position %q", o.Legend)
+	}
+	switch o.Format {
+	case "", ChartFormatPNG, ChartFormatSVG:
+	default:
+		return fmt.Errorf("unknown chart format %q", o.Format)
+	}
+	return validateAnnotations(o.Thresholds, o.Bands, o.Markers)
 }
 
 type ModuleChart struct {
 	rf requestFunc
 }
 
+// Render renders the chart with the default options.
 func (c ModuleChart) Render(title string, series []DataSeries) ([]byte, error) {
+	return c.RenderWithOptions(title, series, nil)
+}
+
+// RenderWithOptions renders the chart with the options. opts may be nil.
+// With the Local option the chart is rendered without the balerter.
+func (c ModuleChart) RenderWithOptions(title string, series []DataSeries, opts *ChartOptions) ([]byte, error) {
+	if opts != nil {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateSeries(series); err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Local {
+		return RenderChart(title, series, opts)
+	}
+
 	req := struct {
-		Title  string       `json:"title"`
-		Series []DataSeries `json:"series"`
+		Title   string        `json:"title"`
+		Series  []DataSeries  `json:"series"`
+		Options *ChartOptions `json:"options,omitempty"`
 	}{
-		Title:  title,
-		Series: series,
+		Title:   title,
+		Series:  series,
+		Options: opts,
 	}
 
 	payload, errMarshal := json.Marshal(req)
@@ -0,0 +1,148 @@
+package coreapi
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Default annotation colors of the chart level annotations.
+// The series level annotations are colored with the series line color by default.
+const (
+	DefaultThresholdColor = "#d62728"
+	DefaultBandColor      = "#ff7f0e"
+	DefaultMarkerColor    = "#7f7f7f"
+)
+
+// ChartThreshold is a horizontal line at the value
+type ChartThreshold struct {
+	Value float64 `json:"value"`
+	Label string  `json:"label,omitempty"`
+	Color string  `json:"color,omitempty"`
+	// LineStyle is one of LineStyleSolid, LineStyleDashed or LineStyleDotted, the threshold is dashed by default
+	LineStyle string `json:"line_style,omitempty"`
+}
+
+// ChartBand is a shaded area between the From and To values
+type ChartBand struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Label string  `json:"label,omitempty"`
+	Color string  `json:"color,omitempty"`
+}
+
+// ChartMarker is a vertical line at the unix timestamp in seconds, e.g. an event time
+type ChartMarker struct {
+	Timestamp float64 `json:"timestamp"`
+	Label     string  `json:"label,omitempty"`
+	Color     string  `json:"color,omitempty"`
+}
+
+// chartAnnotations are the thresholds, bands and markers of the chart or the series
+type chartAnnotations struct {
+	thresholds []ChartThreshold
+	bands      []ChartBand
+	markers    []ChartMarker
+}
+
+// ChartMarkerAt returns the marker at the time
+func ChartMarkerAt(t time.Time, label string) ChartMarker {
+	return ChartMarker{
+		Timestamp: DataItemAt(t, 0).Timestamp,
+		Label:     label,
+	}
+}
+
+// AlertMarkers returns the markers of the alert start and the last change.
+// The last change marker is omitted if it equals the start. It returns nil for the nil alert.
+func AlertMarkers(a *Alert) []ChartMarker {
+	if a == nil {
+		return nil
+	}
+	var result []ChartMarker
+	if !a.Start.IsZero() {
+		result = append(result, ChartMarkerAt(a.Start, "start"))
+	}
+	if !a.LastChange.IsZero() && !a.LastChange.Equal(a.Start) {
+		result = append(result, ChartMarkerAt(a.LastChange, "last change"))
+	}
+	return result
+}
+
+func validateAnnotations(thresholds []ChartThreshold, bands []ChartBand, markers []ChartMarker) error {
+	for i, t := range thresholds {
+		if !isFinite(t.Value) {
+			return fmt.Errorf("threshold %d value must be finite", i)
+		}
+		switch t.LineStyle {
+		case "", LineStyleSolid, LineStyleDashed, LineStyleDotted:
+		default:
+			return fmt.Errorf("unknown line style %q of the threshold %d", t.LineStyle, i)
+		}
+	}
+	for i, b := range bands {
+		if !isFinite(b.From) || !isFinite(b.To) {
+			return fmt.Errorf("band %d range must be finite", i)
+		}
+		if b.From >= b.To {
+			return fmt.Errorf("band %d from must be less than to", i)
+		}
+	}
+	for i, m := range markers {
+		if !isFinite(m.Timestamp) {
+			return fmt.Errorf("marker %d timestamp must be finite", i)
+		}
+	}
+	return nil
+}
+
+// resolveAnnotations merges the chart and series annotations with the colors in the "#rrggbb" form.
+// The series annotations without the color take the series line color.
+func resolveAnnotations(series []DataSeries, opts *ChartOptions) chartAnnotations {
+	var result chartAnnotations
+	add := func(thresholds []ChartThreshold, bands []ChartBand, markers []ChartMarker, threshold, band, marker string) {
+		for _, t := range thresholds {
+			t.Color = annotationColor(t.Color, threshold)
+			if t.LineStyle == "" {
+				t.LineStyle = LineStyleDashed
+			}
+			result.thresholds = append(result.thresholds, t)
+		}
+		for _, b := range bands {
+			b.Color = annotationColor(b.Color, band)
+			result.bands = append(result.bands, b)
+		}
+		for _, m := range markers {
+			m.Color = annotationColor(m.Color, marker)
+			result.markers = append(result.markers, m)
+		}
+	}
+	add(opts.Thresholds, opts.Bands, opts.Markers, DefaultThresholdColor, DefaultBandColor, DefaultMarkerColor)
+	for i, s := range series {
+		line, _ := seriesColors(s, i)
+		add(s.Thresholds, s.Bands, s.Markers, line, line, line)
+	}
+	return result
+}
+
+func annotationColor(c, def string) string {
+	if n, ok := normalizeColor(c); ok {
+		return n
+	}
+	return def
+}
+
+// ranges returns the values and timestamps range of the annotations
+func (a chartAnnotations) ranges() (xMin, xMax, yMin, yMax float64) {
+	xMin, xMax, yMin, yMax = math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, t := range a.thresholds {
+		yMin, yMax = math.Min(yMin, t.Value), math.Max(yMax, t.Value)
+	}
+	for _, b := range a.bands {
+		yMin, yMax = math.Min(yMin, b.From), math.Max(yMax, b.To)
+	}
+	for _, m := range a.markers {
+		xMin, xMax = math.Min(xMin, m.Timestamp), math.Max(xMax, m.Timestamp)
+	}
+	return
+}
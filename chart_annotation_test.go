@@ -0,0 +1,139 @@
+package coreapi
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertMarkers(t *testing.T) {
+	start := time.Unix(1660557600, 0)
+
+	if m := AlertMarkers(nil); m != nil {
+		t.Fatalf("unexpected markers, got %v", m)
+	}
+	if m := AlertMarkers(&Alert{Start: start, LastChange: start}); fmt.Sprint(m) != "[{1.6605576e+09 start }]" {
+		t.Fatalf("unexpected markers, got %v", m)
+	}
+	m := AlertMarkers(&Alert{Start: start, LastChange: start.Add(time.Minute)})
+	if fmt.Sprint(m) != "[{1.6605576e+09 start } {1.66055766e+09 last change }]" {
+		t.Fatalf("unexpected markers, got %v", m)
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	for _, c := range []struct {
+		opts   *ChartOptions
+		expect string
+	}{
+		{&ChartOptions{Thresholds: []ChartThreshold{{Value: math.NaN()}}}, "threshold 0 value must be finite"},
+		{&ChartOptions{Thresholds: []ChartThreshold{{LineStyle: "wavy"}}}, `unknown line style "wavy" of the threshold 0`},
+		{&ChartOptions{Bands: []ChartBand{{From: 1, To: math.Inf(1)}}}, "band 0 range must be finite"},
+		{&ChartOptions{Bands: []ChartBand{{From: 0, To: 1}, {From: 2, To: 1}}}, "band 1 from must be less than to"},
+		{&ChartOptions{Markers: []ChartMarker{{Timestamp: math.Inf(-1)}}}, "marker 0 timestamp must be finite"},
+	} {
+		err := c.opts.Validate()
+		if err == nil || err.Error() != c.expect {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+	}
+
+	_, err := RenderChart("", []DataSeries{{}, {Bands: []ChartBand{{From: 1, To: 1}}}}, nil)
+	if err == nil || err.Error() != "bad annotations of the series 1: band 0 from must be less than to" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestModuleChart_RenderWithOptions_annotations(t *testing.T) {
+	m := ModuleChart{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			expect := `{"title":"t","series":[{"Color":"","LineColor":"","PointColor":"","Data":null,"Thresholds":[{"value":90,"label":"max"}]}],` +
+				`"options":{"bands":[{"from":1,"to":2,"color":"red"}],"markers":[{"timestamp":10,"label":"start"}]}}`
+			if string(body) != expect {
+				t.Fatalf("unexpected body value, got %s", string(body))
+			}
+			return []byte(`""`), nil
+		},
+	}
+
+	_, err := m.RenderWithOptions("t", []DataSeries{{Thresholds: []ChartThreshold{{Value: 90, Label: "max"}}}}, &ChartOptions{
+		Bands:   []ChartBand{{From: 1, To: 2, Color: "red"}},
+		Markers: []ChartMarker{{Timestamp: 10, Label: "start"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestRenderChart_svg_annotations(t *testing.T) {
+	img, err := RenderChart("", []DataSeries{{
+		LineColor:  "blue",
+		Data:       []DataItem{{Timestamp: 0, Value: 0}, {Timestamp: 100, Value: 50}},
+		Thresholds: []ChartThreshold{{Value: 100, Label: "max<"}},
+	}}, &ChartOptions{
+		Format:     ChartFormatSVG,
+		Thresholds: []ChartThreshold{{Value: 25, LineStyle: LineStyleSolid, Color: "#0f0"}},
+		Bands:      []ChartBand{{From: 50, To: 200, Label: "warn"}},
+		Markers:    []ChartMarker{{Timestamp: 200, Label: "start"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	s := string(img)
+	for _, expect := range []string{
+		`<rect x="70.00" y="40.00" width="710.00" height="232.50" fill="#ff7f0e" fill-opacity="0.15"/>`,
+		`<text x="74.00" y="52.00" fill="#ff7f0e">warn</text>`,
+		`<line x1="70.00" y1="311.25" x2="780.00" y2="311.25" stroke="#00ff00"/>`,
+		`<line x1="70.00" y1="195.00" x2="780.00" y2="195.00" stroke="#0000ff" stroke-dasharray="6.00,4.00"/>`,
+		`<text x="776.00" y="191.00" text-anchor="end" fill="#0000ff">max&lt;</text>`,
+		`<line x1="780.00" y1="40.00" x2="780.00" y2="350.00" stroke="#7f7f7f"/>`,
+		`<text x="776.00" y="52.00" text-anchor="end" fill="#7f7f7f">start</text>`,
+	} {
+		if !strings.Contains(s, expect) {
+			t.Fatalf("expected %s in the svg, got %s", expect, s)
+		}
+	}
+
+	yMax := 10.0
+	img, _ = RenderChart("", nil, &ChartOptions{
+		Format:     ChartFormatSVG,
+		YMax:       &yMax,
+		Thresholds: []ChartThreshold{{Value: 20}},
+		Bands:      []ChartBand{{From: 5, To: 30}, {From: 15, To: 30}},
+	})
+	if strings.Contains(string(img), `stroke="#d62728"`) {
+		t.Fatalf("unexpected threshold out of the range, got %s", string(img))
+	}
+	if strings.Count(string(img), `fill-opacity="0.15"`) != 1 {
+		t.Fatalf("unexpected bands, got %s", string(img))
+	}
+}
+
+func TestRenderChart_png_annotations(t *testing.T) {
+	img, err := RenderChart("", nil, &ChartOptions{
+		Width:   200,
+		Height:  150,
+		Bands:   []ChartBand{{From: 0, To: 10, Color: "#0000ff"}},
+		Markers: []ChartMarker{{Timestamp: 5}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	p, errDecode := png.Decode(bytes.NewReader(img))
+	if errDecode != nil {
+		t.Fatalf("unexpected error, got %v", errDecode)
+	}
+	if c := color.RGBAModel.Convert(p.At(100, 70)).(color.RGBA); c != (color.RGBA{R: 217, G: 217, B: 255, A: 255}) {
+		t.Fatalf("unexpected band color, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(p.At(125, 70)).(color.RGBA); c != parseColor(DefaultMarkerColor) {
+		t.Fatalf("unexpected marker color, got %v", c)
+	}
+}
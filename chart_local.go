@@ -0,0 +1,672 @@
+package coreapi
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local chart defaults
+const (
+	DefaultChartWidth     = 800
+	DefaultChartHeight    = 400
+	DefaultChartLineWidth = 1.5
+)
+
+// chartPalette colors the series without the defined color
+var chartPalette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+var chartColorNames = map[string]string{
+	"black":  "#000000",
+	"white":  "#ffffff",
+	"gray":   "#808080",
+	"grey":   "#808080",
+	"red":    "#ff0000",
+	"green":  "#008000",
+	"blue":   "#0000ff",
+	"yellow": "#ffff00",
+	"orange": "#ffa500",
+	"purple": "#800080",
+	"pink":   "#ffc0cb",
+	"brown":  "#a52a2a",
+	"cyan":   "#00ffff",
+}
+
+const (
+	chartMarginLeft   = 70
+	chartMarginRight  = 20
+	chartMarginTop    = 40
+	chartMarginBottom = 50
+	chartTicks        = 5
+)
+
+// RenderChart renders the chart in the library, without the balerter.
+// The colors may be defined by names, e.g. "red", or in the "#rgb" and "#rrggbb" forms,
+// the series with an unknown or empty color are colored from the palette.
+// The points are drawn for the series with the PointColor.
+// The PNG output has no text, so the title, the labels and the legend are drawn in SVG only.
+// The time axis is labeled in UTC. opts may be nil, the format defaults to PNG.
+func RenderChart(title string, series []DataSeries, opts *ChartOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &ChartOptions{}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateSeries(series); err != nil {
+		return nil, err
+	}
+
+	l := newChartLayout(series, opts)
+
+	if opts.Format == ChartFormatSVG {
+		return l.svg(title, series, opts), nil
+	}
+	return l.png(series)
+}
+
+func validateSeries(series []DataSeries) error {
+	for i, s := range series {
+		if math.IsNaN(s.LineWidth) || s.LineWidth < 0 || s.LineWidth > MaxChartLineWidth {
+			return fmt.Errorf("line width of the series %d must be between 0 and %d", i, MaxChartLineWidth)
+		}
+		switch s.LineStyle {
+		case "", LineStyleSolid, LineStyleDashed, LineStyleDotted:
+		default:
+			return fmt.Errorf("unknown line style %q of the series %d", s.LineStyle, i)
+		}
+		if err := validateAnnotations(s.Thresholds, s.Bands, s.Markers); err != nil {
+			return fmt.Errorf("bad annotations of the series %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// chartLayout maps the data to the image coordinates
+type chartLayout struct {
+	width, height          int
+	left, right, top, bott float64
+	xMin, xMax, yMin, yMax float64
+	ann                    chartAnnotations
+}
+
+func newChartLayout(series []DataSeries, opts *ChartOptions) *chartLayout {
+	l := &chartLayout{
+		width:  opts.Width,
+		height: opts.Height,
+		xMin:   math.Inf(1),
+		xMax:   math.Inf(-1),
+		yMin:   math.Inf(1),
+		yMax:   math.Inf(-1),
+	}
+	if l.width == 0 {
+		l.width = DefaultChartWidth
+	}
+	if l.height == 0 {
+		l.height = DefaultChartHeight
+	}
+	l.left = chartMarginLeft
+	l.right = float64(l.width - chartMarginRight)
+	l.top = chartMarginTop
+	l.bott = float64(l.height - chartMarginBottom)
+
+	for _, s := range series {
+		for _, d := range s.Data {
+			if !isFinite(d.Timestamp) || !isFinite(d.Value) {
+				continue
+			}
+			l.xMin = math.Min(l.xMin, d.Timestamp)
+			l.xMax = math.Max(l.xMax, d.Timestamp)
+			l.yMin = math.Min(l.yMin, d.Value)
+			l.yMax = math.Max(l.yMax, d.Value)
+		}
+	}
+	l.ann = resolveAnnotations(series, opts)
+	xMin, xMax, yMin, yMax := l.ann.ranges()
+	l.xMin, l.xMax = math.Min(l.xMin, xMin), math.Max(l.xMax, xMax)
+	l.yMin, l.yMax = math.Min(l.yMin, yMin), math.Max(l.yMax, yMax)
+	if math.IsInf(l.xMin, 1) {
+		l.xMin, l.xMax = 0, 1
+	}
+	if math.IsInf(l.yMin, 1) {
+		l.yMin, l.yMax = 0, 1
+	}
+	if opts.YMin != nil {
+		l.yMin = *opts.YMin
+	}
+	if opts.YMax != nil {
+		l.yMax = *opts.YMax
+	}
+	if l.xMin == l.xMax {
+		l.xMin, l.xMax = l.xMin-1, l.xMax+1
+	}
+	if l.yMin >= l.yMax {
+		l.yMin, l.yMax = l.yMin-1, l.yMin+1
+	}
+	return l
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func (l *chartLayout) x(ts float64) float64 {
+	return l.left + (ts-l.xMin)/(l.xMax-l.xMin)*(l.right-l.left)
+}
+
+func (l *chartLayout) y(v float64) float64 {
+	return l.bott - (v-l.yMin)/(l.yMax-l.yMin)*(l.bott-l.top)
+}
+
+// bandRect returns the top and bottom of the band clipped to the plot, ok is false for the band out of the plot
+func (l *chartLayout) bandRect(b ChartBand) (top, bottom float64, ok bool) {
+	from, to := math.Max(b.From, l.yMin), math.Min(b.To, l.yMax)
+	if from >= to {
+		return 0, 0, false
+	}
+	return l.y(to), l.y(from), true
+}
+
+// inYRange reports whether the value is within the plot
+func (l *chartLayout) inYRange(v float64) bool {
+	return v >= l.yMin && v <= l.yMax
+}
+
+// chartClampMargin bounds the image coordinates of the points far out of the plot, so the clipping is precise.
+// The clamped line direction differs from the real one by less than a pixel within the plot.
+const chartClampMargin = 1e6
+
+// point returns the data item in the image coordinates, ok is false for the non-finite item
+func (l *chartLayout) point(d DataItem) ([2]float64, bool) {
+	if !isFinite(d.Timestamp) || !isFinite(d.Value) {
+		return [2]float64{}, false
+	}
+	clamp := func(v, min, max float64) float64 {
+		return math.Max(min-chartClampMargin, math.Min(max+chartClampMargin, v))
+	}
+	return [2]float64{
+		clamp(l.x(d.Timestamp), l.left, l.right),
+		clamp(l.y(d.Value), l.top, l.bott),
+	}, true
+}
+
+// inPlot reports whether the point in the image coordinates is within the plot
+func (l *chartLayout) inPlot(p [2]float64) bool {
+	return p[0] >= l.left && p[0] <= l.right && p[1] >= l.top && p[1] <= l.bott
+}
+
+// segments returns the polylines of the series in the image coordinates, clipped to the plot.
+// The non-finite values and the parts out of the plot break the line.
+func (l *chartLayout) segments(s DataSeries) [][][2]float64 {
+	var result [][][2]float64
+	var cur [][2]float64
+	flush := func() {
+		if len(cur) > 0 {
+			result = append(result, cur)
+			cur = nil
+		}
+	}
+
+	var prev [2]float64
+	hasPrev := false
+	for _, d := range s.Data {
+		p, ok := l.point(d)
+		if !ok {
+			flush()
+			hasPrev = false
+			continue
+		}
+		if !hasPrev {
+			if l.inPlot(p) {
+				cur = append(cur, p)
+			}
+			prev, hasPrev = p, true
+			continue
+		}
+
+		t0, t1, visible := clipLine(prev, p, l.left, l.top, l.right, l.bott)
+		if !visible {
+			flush()
+			prev = p
+			continue
+		}
+		if t0 > 0 || len(cur) == 0 {
+			flush()
+			cur = append(cur, lerpPoint(prev, p, t0))
+		}
+		cur = append(cur, lerpPoint(prev, p, t1))
+		if t1 < 1 {
+			flush()
+		}
+		prev = p
+	}
+	flush()
+
+	return result
+}
+
+// points returns the data items within the plot in the image coordinates
+func (l *chartLayout) points(s DataSeries) [][2]float64 {
+	var result [][2]float64
+	for _, d := range s.Data {
+		if p, ok := l.point(d); ok && l.inPlot(p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// clipLine clips the line from p0 to p1 to the rectangle with the Liang-Barsky algorithm.
+// It returns the parameters of the visible part, visible is false if the line is out of the rectangle.
+func clipLine(p0, p1 [2]float64, minX, minY, maxX, maxY float64) (t0, t1 float64, visible bool) {
+	t0, t1 = 0, 1
+	dx, dy := p1[0]-p0[0], p1[1]-p0[1]
+	for _, e := range [4][2]float64{
+		{-dx, p0[0] - minX},
+		{dx, maxX - p0[0]},
+		{-dy, p0[1] - minY},
+		{dy, maxY - p0[1]},
+	} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			t0 = math.Max(t0, r)
+		} else {
+			t1 = math.Min(t1, r)
+		}
+		if t0 > t1 {
+			return 0, 0, false
+		}
+	}
+	return t0, t1, true
+}
+
+func lerpPoint(p0, p1 [2]float64, t float64) [2]float64 {
+	return [2]float64{p0[0] + (p1[0]-p0[0])*t, p0[1] + (p1[1]-p0[1])*t}
+}
+
+func (l *chartLayout) xTickLabel(ts float64) string {
+	t := time.Unix(0, int64(ts*float64(time.Second))).UTC()
+	if l.xMax-l.xMin > 2*24*60*60 {
+		return t.Format("01-02 15:04")
+	}
+	return t.Format("15:04:05")
+}
+
+func formatChartValue(v float64, unit string) string {
+	return strconv.FormatFloat(v, 'g', 4, 64) + unit
+}
+
+// seriesColors returns the line and point colors of the series in the "#rrggbb" form.
+// The point color is empty if the points are not drawn.
+func seriesColors(s DataSeries, idx int) (string, string) {
+	line := chartPalette[idx%len(chartPalette)]
+	if c, ok := normalizeColor(s.Color); ok {
+		line = c
+	}
+	point := ""
+	if s.PointColor != "" {
+		point = line
+		if c, ok := normalizeColor(s.PointColor); ok {
+			point = c
+		}
+	}
+	if c, ok := normalizeColor(s.LineColor); ok {
+		line = c
+	}
+	return line, point
+}
+
+func seriesLineWidth(s DataSeries) float64 {
+	if s.LineWidth > 0 {
+		return s.LineWidth
+	}
+	return DefaultChartLineWidth
+}
+
+// dashPattern returns the on and off lengths of the line style, zeros for the solid line
+func dashPattern(style string, width float64) (float64, float64) {
+	switch style {
+	case LineStyleDashed:
+		return 6 * width, 4 * width
+	case LineStyleDotted:
+		return width, 2 * width
+	}
+	return 0, 0
+}
+
+// normalizeColor converts the color name or the "#rgb" form to the "#rrggbb" form
+func normalizeColor(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := chartColorNames[s]; ok {
+		return c, true
+	}
+	if !strings.HasPrefix(s, "#") {
+		return "", false
+	}
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return "", false
+	}
+	return "#" + hex, true
+}
+
+func parseColor(s string) color.RGBA {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+func (l *chartLayout) svg(title string, series []DataSeries, opts *ChartOptions) []byte {
+	b := &bytes.Buffer{}
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`,
+		l.width, l.height, l.width, l.height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#ffffff"/>`, l.width, l.height)
+	if title != "" {
+		fmt.Fprintf(b, `<text x="%s" y="24" text-anchor="middle" font-size="16">%s</text>`, f(float64(l.width)/2), html.EscapeString(title))
+	}
+
+	for _, band := range l.ann.bands {
+		top, bottom, ok := l.bandRect(band)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s" fill-opacity="0.15"/>`,
+			f(l.left), f(top), f(l.right-l.left), f(bottom-top), band.Color)
+		if band.Label != "" {
+			fmt.Fprintf(b, `<text x="%s" y="%s" fill="%s">%s</text>`, f(l.left+4), f(top+12), band.Color, html.EscapeString(band.Label))
+		}
+	}
+
+	for i := 0; i <= chartTicks; i++ {
+		v := l.yMin + (l.yMax-l.yMin)*float64(i)/chartTicks
+		y := l.y(v)
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#e0e0e0"/>`, f(l.left), f(y), f(l.right), f(y))
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="end">%s</text>`, f(l.left-6), f(y+4), html.EscapeString(formatChartValue(v, opts.Unit)))
+
+		ts := l.xMin + (l.xMax-l.xMin)*float64(i)/chartTicks
+		x := l.x(ts)
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle">%s</text>`, f(x), f(l.bott+16), l.xTickLabel(ts))
+	}
+	fmt.Fprintf(b, `<path d="M%s %sV%sH%s" fill="none" stroke="#000000"/>`, f(l.left), f(l.top), f(l.bott), f(l.right))
+
+	if opts.XLabel != "" {
+		fmt.Fprintf(b, `<text x="%s" y="%d" text-anchor="middle">%s</text>`, f((l.left+l.right)/2), l.height-10, html.EscapeString(opts.XLabel))
+	}
+	if opts.YLabel != "" {
+		fmt.Fprintf(b, `<text x="14" y="%s" text-anchor="middle" transform="rotate(-90 14 %s)">%s</text>`,
+			f((l.top+l.bott)/2), f((l.top+l.bott)/2), html.EscapeString(opts.YLabel))
+	}
+
+	for i, s := range series {
+		line, point := seriesColors(s, i)
+		width := seriesLineWidth(s)
+		dash := ""
+		if on, off := dashPattern(s.LineStyle, width); on > 0 {
+			dash = fmt.Sprintf(` stroke-dasharray="%s,%s"`, f(on), f(off))
+		}
+		for _, seg := range l.segments(s) {
+			points := make([]string, 0, len(seg))
+			for _, p := range seg {
+				points = append(points, f(p[0])+","+f(p[1]))
+			}
+			fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s"%s/>`, strings.Join(points, " "), line, f(width), dash)
+		}
+		if point == "" {
+			continue
+		}
+		for _, p := range l.points(s) {
+			fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`, f(p[0]), f(p[1]), f(width+1.5), point)
+		}
+	}
+
+	for _, t := range l.ann.thresholds {
+		if !l.inYRange(t.Value) {
+			continue
+		}
+		y := l.y(t.Value)
+		dash := ""
+		if on, off := dashPattern(t.LineStyle, 1); on > 0 {
+			dash = fmt.Sprintf(` stroke-dasharray="%s,%s"`, f(on), f(off))
+		}
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s"%s/>`, f(l.left), f(y), f(l.right), f(y), t.Color, dash)
+		if t.Label != "" {
+			fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="end" fill="%s">%s</text>`, f(l.right-4), f(y-4), t.Color, html.EscapeString(t.Label))
+		}
+	}
+	for _, m := range l.ann.markers {
+		x := l.x(m.Timestamp)
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s"/>`, f(x), f(l.top), f(x), f(l.bott), m.Color)
+		if m.Label == "" {
+			continue
+		}
+		if x > (l.left+l.right)/2 {
+			fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="end" fill="%s">%s</text>`, f(x-4), f(l.top+12), m.Color, html.EscapeString(m.Label))
+		} else {
+			fmt.Fprintf(b, `<text x="%s" y="%s" fill="%s">%s</text>`, f(x+4), f(l.top+12), m.Color, html.EscapeString(m.Label))
+		}
+	}
+
+	l.svgLegend(b, series, opts)
+
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// svgLegend draws the names of the series. The legend is drawn at the top right corner of the plot
+// for LegendTop and LegendRight, and at the bottom right corner for LegendBottom.
+func (l *chartLayout) svgLegend(b *bytes.Buffer, series []DataSeries, opts *ChartOptions) {
+	if opts.Legend == LegendNone {
+		return
+	}
+	y := l.top + 14
+	if opts.Legend == LegendBottom {
+		y = l.bott - 8
+		for _, s := range series {
+			if s.Name != "" {
+				y -= 16
+			}
+		}
+		y += 16
+	}
+	for i, s := range series {
+		if s.Name == "" {
+			continue
+		}
+		line, _ := seriesColors(s, i)
+		fmt.Fprintf(b, `<rect x="%.2f" y="%.2f" width="10" height="10" fill="%s"/>`, l.right-150, y-9, line)
+		fmt.Fprintf(b, `<text x="%.2f" y="%.2f">%s</text>`, l.right-135, y, html.EscapeString(s.Name))
+		y += 16
+	}
+}
+
+func (l *chartLayout) png(series []DataSeries) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	fillRect(img, img.Bounds(), color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+
+	for _, band := range l.ann.bands {
+		top, bottom, ok := l.bandRect(band)
+		if !ok {
+			continue
+		}
+		blendRect(img, image.Rect(int(math.Round(l.left)), int(math.Round(top)), int(math.Round(l.right)), int(math.Round(bottom))), parseColor(band.Color), 0.15)
+	}
+
+	grid := color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+	for i := 0; i <= chartTicks; i++ {
+		y := l.y(l.yMin + (l.yMax-l.yMin)*float64(i)/chartTicks)
+		drawLine(img, [2]float64{l.left, y}, [2]float64{l.right, y}, grid, 1, 0, 0, nil)
+	}
+	black := color.RGBA{A: 0xff}
+	drawLine(img, [2]float64{l.left, l.top}, [2]float64{l.left, l.bott}, black, 1, 0, 0, nil)
+	drawLine(img, [2]float64{l.left, l.bott}, [2]float64{l.right, l.bott}, black, 1, 0, 0, nil)
+
+	for i, s := range series {
+		line, point := seriesColors(s, i)
+		width := seriesLineWidth(s)
+		on, off := dashPattern(s.LineStyle, width)
+		for _, seg := range l.segments(s) {
+			var dist float64
+			for j := 1; j < len(seg); j++ {
+				drawLine(img, seg[j-1], seg[j], parseColor(line), width, on, off, &dist)
+			}
+			if len(seg) == 1 && point == "" {
+				drawPoint(img, seg[0], parseColor(line), width)
+			}
+		}
+		if point == "" {
+			continue
+		}
+		for _, p := range l.points(s) {
+			drawPoint(img, p, parseColor(point), 2*width+3)
+		}
+	}
+
+	for _, t := range l.ann.thresholds {
+		if !l.inYRange(t.Value) {
+			continue
+		}
+		y := l.y(t.Value)
+		on, off := dashPattern(t.LineStyle, 1)
+		var dist float64
+		drawLine(img, [2]float64{l.left, y}, [2]float64{l.right, y}, parseColor(t.Color), 1, on, off, &dist)
+	}
+	for _, m := range l.ann.markers {
+		x := l.x(m.Timestamp)
+		drawLine(img, [2]float64{x, l.top}, [2]float64{x, l.bott}, parseColor(m.Color), 1, 0, 0, nil)
+	}
+
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, img); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// blendRect blends the color with the opacity over the rectangle
+func blendRect(img *image.RGBA, r image.Rectangle, c color.RGBA, opacity float64) {
+	blend := func(dst, src uint8) uint8 {
+		return uint8(math.Round(float64(dst)*(1-opacity) + float64(src)*opacity))
+	}
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst := img.RGBAAt(x, y)
+			img.SetRGBA(x, y, color.RGBA{R: blend(dst.R, c.R), G: blend(dst.G, c.G), B: blend(dst.B, c.B), A: 0xff})
+		}
+	}
+}
+
+// drawPoint draws the square of the size centered at p
+func drawPoint(img *image.RGBA, p [2]float64, c color.RGBA, size float64) {
+	h := math.Max(size, 1) / 2
+	fillRect(img, image.Rect(int(math.Round(p[0]-h)), int(math.Round(p[1]-h)), int(math.Round(p[0]+h)), int(math.Round(p[1]+h))), c)
+}
+
+// drawLine draws the line from p0 to p1. For the dashed line, on and off are the dash lengths and
+// dist is the length drawn before, so the pattern continues across the polyline.
+// The line is clipped to the image and each pixel is drawn once by its distance to the line,
+// so the work is bounded by the line area within the image.
+func drawLine(img *image.RGBA, p0, p1 [2]float64, c color.RGBA, width, on, off float64, dist *float64) {
+	dx, dy := p1[0]-p0[0], p1[1]-p0[1]
+	length := math.Hypot(dx, dy)
+	var start float64
+	if dist != nil {
+		start = *dist
+		*dist += length
+	}
+
+	r := math.Max(width, 1) / 2
+	b := img.Bounds()
+	t0, t1, visible := clipLine(p0, p1, float64(b.Min.X)-r, float64(b.Min.Y)-r, float64(b.Max.X)+r, float64(b.Max.Y)+r)
+	if !visible {
+		return
+	}
+	q0, q1 := lerpPoint(p0, p1, t0), lerpPoint(p0, p1, t1)
+	ex, ey := q1[0]-q0[0], q1[1]-q0[1]
+	l2 := ex*ex + ey*ey
+
+	yMin := maxInt(int(math.Ceil(math.Min(q0[1], q1[1])-r)), b.Min.Y)
+	yMax := minInt(int(math.Floor(math.Max(q0[1], q1[1])+r)), b.Max.Y-1)
+	for y := yMin; y <= yMax; y++ {
+		cy := float64(y)
+		// the part of the line within r of the row
+		ta, tb := 0.0, 1.0
+		if ey != 0 {
+			ta, tb = (cy-r-q0[1])/ey, (cy+r-q0[1])/ey
+			if ta > tb {
+				ta, tb = tb, ta
+			}
+			ta, tb = math.Max(ta, 0), math.Min(tb, 1)
+		}
+		xa, xb := q0[0]+ex*ta, q0[0]+ex*tb
+		if xa > xb {
+			xa, xb = xb, xa
+		}
+
+		xMin := maxInt(int(math.Ceil(xa-r)), b.Min.X)
+		xMax := minInt(int(math.Floor(xb+r)), b.Max.X-1)
+		for x := xMin; x <= xMax; x++ {
+			cx := float64(x)
+			t := 0.0
+			if l2 > 0 {
+				t = math.Max(0, math.Min(1, ((cx-q0[0])*ex+(cy-q0[1])*ey)/l2))
+			}
+			if px, py := cx-q0[0]-ex*t, cy-q0[1]-ey*t; px*px+py*py > r*r {
+				continue
+			}
+			if on > 0 {
+				pos := start + (t0+(t1-t0)*t)*length
+				if pos-math.Floor(pos/(on+off))*(on+off) >= on {
+					continue
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
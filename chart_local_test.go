@@ -0,0 +1,271 @@
+package coreapi
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderChart_png(t *testing.T) {
+	img, err := RenderChart("t", []DataSeries{{
+		Color: "#f00",
+		Data:  []DataItem{{Timestamp: 0, Value: 0}, {Timestamp: 10, Value: 10}},
+	}, {
+		LineColor:  "blue",
+		PointColor: "green",
+		LineStyle:  LineStyleDashed,
+		Data:       []DataItem{{Timestamp: 0, Value: 5}, {Timestamp: 10, Value: 5}},
+	}}, &ChartOptions{Width: 200, Height: 150})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	p, errDecode := png.Decode(bytes.NewReader(img))
+	if errDecode != nil {
+		t.Fatalf("unexpected error, got %v", errDecode)
+	}
+	if p.Bounds().Dx() != 200 || p.Bounds().Dy() != 150 {
+		t.Fatalf("unexpected image size, got %v", p.Bounds())
+	}
+
+	colors := map[color.RGBA]int{}
+	for y := 0; y < 150; y++ {
+		for x := 0; x < 200; x++ {
+			colors[color.RGBAModel.Convert(p.At(x, y)).(color.RGBA)]++
+		}
+	}
+	for _, c := range []string{"#ffffff", "#000000", "#ff0000", "#0000ff", "#008000"} {
+		if colors[parseColor(c)] == 0 {
+			t.Fatalf("expected color %s in the image", c)
+		}
+	}
+
+	if c := color.RGBAModel.Convert(p.At(70, 60)).(color.RGBA); c != parseColor("#000000") {
+		t.Fatalf("unexpected axis color, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(p.At(180, 40)).(color.RGBA); c != parseColor("#ff0000") {
+		t.Fatalf("unexpected line color, got %v", c)
+	}
+}
+
+func TestRenderChart_svg(t *testing.T) {
+	yMin := 0.0
+	img, err := RenderChart("<cpu>", []DataSeries{{
+		Name:       "a&b",
+		PointColor: "#123456",
+		LineStyle:  LineStyleDotted,
+		LineWidth:  2,
+		Data:       []DataItem{{Timestamp: 0, Value: 50}, {Timestamp: math.NaN(), Value: 1}, {Timestamp: 60, Value: 100}, {Timestamp: 120, Value: 70}},
+	}}, &ChartOptions{Format: ChartFormatSVG, YMin: &yMin, Unit: "%", XLabel: "time", YLabel: "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	s := string(img)
+	for _, expect := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="800" height="400"`,
+		`>&lt;cpu&gt;</text>`,
+		`>a&amp;b</text>`,
+		`>time</text>`,
+		`>usage</text>`,
+		`>0%</text>`,
+		`>100%</text>`,
+		`>00:00:00</text>`,
+		`>00:02:00</text>`,
+		`<polyline points="70.00,195.00" fill="none" stroke="#1f77b4" stroke-width="2.00" stroke-dasharray="2.00,4.00"/>`,
+		`<polyline points="425.00,40.00 780.00,133.00" fill="none" stroke="#1f77b4"`,
+		`<circle cx="780.00" cy="133.00" r="3.50" fill="#123456"/>`,
+	} {
+		if !strings.Contains(s, expect) {
+			t.Fatalf("expected %s in the svg, got %s", expect, s)
+		}
+	}
+	if !strings.HasSuffix(s, "</svg>") {
+		t.Fatalf("unexpected svg end, got %s", s)
+	}
+	if ImageDataURI(img)[:25] != "data:image/svg+xml;base64" {
+		t.Fatalf("unexpected data uri, got %s", ImageDataURI(img)[:25])
+	}
+}
+
+func TestRenderChart_svg_legend(t *testing.T) {
+	series := []DataSeries{{Name: "a"}, {}, {Name: "b"}}
+
+	img, _ := RenderChart("", series, &ChartOptions{Format: ChartFormatSVG, Legend: LegendNone})
+	if strings.Contains(string(img), ">a</text>") {
+		t.Fatalf("unexpected legend in the svg")
+	}
+
+	img, _ = RenderChart("", series, &ChartOptions{Format: ChartFormatSVG})
+	if !strings.Contains(string(img), `<rect x="630.00" y="45.00" width="10" height="10" fill="#1f77b4"/><text x="645.00" y="54.00">a</text>`) ||
+		!strings.Contains(string(img), `<rect x="630.00" y="61.00" width="10" height="10" fill="#2ca02c"/><text x="645.00" y="70.00">b</text>`) {
+		t.Fatalf("unexpected legend, got %s", string(img))
+	}
+}
+
+func TestRenderChart_error(t *testing.T) {
+	for opts, expect := range map[*ChartOptions]string{
+		{Width: -1}:     "chart size must not be negative",
+		{Width: 10}:     "chart size must be at least 100",
+		{Height: 99}:    "chart size must be at least 100",
+		{Format: "gif"}: `unknown chart format "gif"`,
+	} {
+		_, err := RenderChart("", nil, opts)
+		if err == nil || err.Error() != expect {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+	}
+
+	_, err := RenderChart("", []DataSeries{{LineStyle: "wavy"}}, nil)
+	if err == nil || err.Error() != `unknown line style "wavy" of the series 0` {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+
+	for _, width := range []float64{math.NaN(), -1, 33} {
+		_, err := RenderChart("", []DataSeries{{}, {LineWidth: width}}, nil)
+		if err == nil || err.Error() != "line width of the series 1 must be between 0 and 32" {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+	}
+}
+
+func TestModuleChart_RenderWithOptions_local(t *testing.T) {
+	m := ModuleChart{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			return nil, fmt.Errorf("unexpected call %s", path)
+		},
+	}
+
+	img, err := m.RenderWithOptions("t", nil, &ChartOptions{Local: true})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if ImageDataURI(img)[:21] != "data:image/png;base64" {
+		t.Fatalf("unexpected data uri, got %s", ImageDataURI(img)[:21])
+	}
+}
+
+func TestNormalizeColor(t *testing.T) {
+	for in, expect := range map[string]string{
+		"Red":      "#ff0000",
+		"#ABC":     "#aabbcc",
+		" #a1b2c3": "#a1b2c3",
+		"#12":      "",
+		"#gggggg":  "",
+		"rgb(1,2)": "",
+		"":         "",
+	} {
+		c, ok := normalizeColor(in)
+		if c != expect || ok != (expect != "") {
+			t.Fatalf("unexpected color value for %q, got %s", in, c)
+		}
+	}
+}
+
+func TestRenderChart_out_of_range(t *testing.T) {
+	yMin, yMax := 0.0, 100.0
+	series := []DataSeries{{
+		PointColor: "red",
+		Data:       []DataItem{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 1e300}, {Timestamp: 3, Value: 50}, {Timestamp: 4, Value: -1e300}},
+	}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := RenderChart("t", series, &ChartOptions{YMin: &yMin, YMax: &yMax}); err != nil {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the chart with the out of range values is not rendered in time")
+	}
+
+	img, err := RenderChart("t", series, &ChartOptions{YMin: &yMin, YMax: &yMax, Format: ChartFormatSVG})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	s := string(img)
+	for _, expect := range []string{
+		`<polyline points="70.00,346.90 70.07,40.00" fill="none"`,
+		`<polyline points="543.30,40.00 543.33,195.00 543.37,350.00" fill="none"`,
+		`<circle cx="70.00" cy="346.90"`,
+		`<circle cx="543.33" cy="195.00"`,
+	} {
+		if !strings.Contains(s, expect) {
+			t.Fatalf("expected %s in the svg, got %s", expect, s)
+		}
+	}
+	if strings.Count(s, "<circle") != 2 {
+		t.Fatalf("unexpected points out of the plot, got %s", s)
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	for _, c := range []struct {
+		p0, p1  [2]float64
+		t0, t1  float64
+		visible bool
+	}{
+		{[2]float64{1, 1}, [2]float64{2, 2}, 0, 1, true},
+		{[2]float64{-10, 5}, [2]float64{20, 5}, 1.0 / 3, 2.0 / 3, true},
+		{[2]float64{-10, -10}, [2]float64{-5, 20}, 0, 0, false},
+		{[2]float64{5, 20}, [2]float64{6, 20}, 0, 0, false},
+	} {
+		t0, t1, visible := clipLine(c.p0, c.p1, 0, 0, 10, 10)
+		if visible != c.visible || math.Abs(t0-c.t0) > 1e-9 || math.Abs(t1-c.t1) > 1e-9 {
+			t.Fatalf("unexpected clip of %v-%v, got %v %v %v", c.p0, c.p1, t0, t1, visible)
+		}
+	}
+}
+
+func TestRenderChart_thick_lines(t *testing.T) {
+	var data []DataItem
+	for i := 0; i < 200; i++ {
+		data = append(data, DataItem{Timestamp: float64(i), Value: float64(i % 2)})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := RenderChart("", []DataSeries{{Data: data, LineWidth: MaxChartLineWidth, LineStyle: LineStyleDashed}},
+			&ChartOptions{Width: 2000, Height: 2000})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	case <-time.After(time.Second * 30):
+		t.Fatalf("the chart is not rendered in time")
+	}
+}
+
+func TestDrawLine(t *testing.T) {
+	red := parseColor("red")
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	drawLine(img, [2]float64{2, 10}, [2]float64{17, 10}, red, 3, 0, 0, nil)
+
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			// the line with the round caps within 1.5 px of the segment
+			d := math.Abs(float64(y) - 10)
+			if x < 2 {
+				d = math.Hypot(float64(x-2), d)
+			} else if x > 17 {
+				d = math.Hypot(float64(x-17), d)
+			}
+			if (img.RGBAAt(x, y) == red) != (d <= 1.5) {
+				t.Fatalf("unexpected pixel %d,%d", x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package coreapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataItemAt returns the data item for the time. The timestamp is the unix time in seconds.
+func DataItemAt(t time.Time, value float64) DataItem {
+	return DataItem{Timestamp: float64(t.UnixNano()) / float64(time.Second), Value: value}
+}
+
+// Time returns the data item timestamp as time.Time.
+func (d DataItem) Time() time.Time {
+	sec, frac := math.Modf(d.Timestamp)
+	return time.Unix(int64(sec), int64(math.Round(frac*float64(time.Second))))
+}
+
+// SeriesFromRows converts the SQL datasource result (the JSON array of rows) to the data series,
+// one per value column. The series are named by the value columns. If the value columns are not defined,
+// all columns, except the time column, are used.
+//
+// The time column may be a unix timestamp in seconds or a RFC3339 string.
+// The values may be numbers, numeric strings or nulls. The null values are skipped.
+func SeriesFromRows(data []byte, timeColumn string, valueColumns ...string) ([]DataSeries, error) {
+	var rows []map[string]interface{}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rows: %w", err)
+	}
+
+	if len(valueColumns) == 0 && len(rows) > 0 {
+		for k := range rows[0] {
+			if k != timeColumn {
+				valueColumns = append(valueColumns, k)
+			}
+		}
+		sort.Strings(valueColumns)
+	}
+
+	series := make([]DataSeries, len(valueColumns))
+	for i, col := range valueColumns {
+		series[i].Name = col
+	}
+
+	for n, row := range rows {
+		ts, err := parseSeriesTime(row[timeColumn])
+		if err != nil {
+			return nil, fmt.Errorf("bad time column %s in row %d: %w", timeColumn, n, err)
+		}
+		for i, col := range valueColumns {
+			raw, ok := row[col]
+			if !ok {
+				return nil, fmt.Errorf("column %s is missing in row %d", col, n)
+			}
+			if raw == nil {
+				continue
+			}
+			v, err := parseSeriesValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("bad value column %s in row %d: %w", col, n, err)
+			}
+			series[i].Data = append(series[i].Data, DataItem{Timestamp: ts, Value: v})
+		}
+	}
+
+	return series, nil
+}
+
+// SeriesFromPrometheusRange converts the prometheus range query result (matrix) to the data series,
+// one per metric. The series are named by the metric labels, e.g. `up{job="node"}`.
+func SeriesFromPrometheusRange(data []byte) ([]DataSeries, error) {
+	return seriesFromMatrix(data)
+}
+
+// SeriesFromLokiMatrix converts the loki metric range query result (matrix) to the data series,
+// one per stream. The series are named by the stream labels.
+func SeriesFromLokiMatrix(data []byte) ([]DataSeries, error) {
+	return seriesFromMatrix(data)
+}
+
+type matrixSample struct {
+	Metric map[string]string   `json:"metric"`
+	Stream map[string]string   `json:"stream"`
+	Values [][]json.RawMessage `json:"values"`
+}
+
+// seriesFromMatrix parses the matrix as is, wrapped in {"resultType":"matrix","result":[...]} or in the
+// full API response {"status":"success","data":{...}}
+func seriesFromMatrix(data []byte) ([]DataSeries, error) {
+	var samples []matrixSample
+
+	if err := json.Unmarshal(data, &samples); err != nil {
+		var wrapped struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+			Data       json.RawMessage `json:"data"`
+		}
+		if errWrapped := json.Unmarshal(data, &wrapped); errWrapped != nil {
+			return nil, fmt.Errorf("failed to unmarshal matrix: %w", err)
+		}
+		if wrapped.Data != nil {
+			return seriesFromMatrix(wrapped.Data)
+		}
+		if wrapped.ResultType != "matrix" {
+			return nil, fmt.Errorf("unexpected result type %q, expect matrix", wrapped.ResultType)
+		}
+		if errResult := json.Unmarshal(wrapped.Result, &samples); errResult != nil {
+			return nil, fmt.Errorf("failed to unmarshal matrix: %w", errResult)
+		}
+	}
+
+	series := make([]DataSeries, 0, len(samples))
+	for _, s := range samples {
+		labels := s.Metric
+		if labels == nil {
+			labels = s.Stream
+		}
+		ds := DataSeries{Name: labelsName(labels)}
+		for _, pair := range s.Values {
+			if len(pair) != 2 {
+				return nil, fmt.Errorf("bad sample of %s: expect [timestamp, value]", ds.Name)
+			}
+			ts, err := strconv.ParseFloat(strings.Trim(string(pair[0]), `"`), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad sample timestamp of %s: %w", ds.Name, err)
+			}
+			v, err := strconv.ParseFloat(strings.Trim(string(pair[1]), `"`), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad sample value of %s: %w", ds.Name, err)
+			}
+			ds.Data = append(ds.Data, DataItem{Timestamp: ts, Value: v})
+		}
+		series = append(series, ds)
+	}
+
+	return series, nil
+}
+
+// labelsName returns the prometheus style name of the labels set, e.g. `up{instance="a", job="b"}`
+func labelsName(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+
+	name := labels["__name__"]
+	if len(pairs) == 0 && name != "" {
+		return name
+	}
+
+	return name + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// Downsample reduces the series data to at most maxPoints items. The data is split to equal buckets
+// and each bucket is replaced with the average timestamp and value.
+func Downsample(series DataSeries, maxPoints int) DataSeries {
+	n := len(series.Data)
+	if maxPoints <= 0 || n <= maxPoints {
+		return series
+	}
+
+	bucket := (n + maxPoints - 1) / maxPoints
+
+	data := make([]DataItem, 0, maxPoints)
+	for i := 0; i < n; i += bucket {
+		end := i + bucket
+		if end > n {
+			end = n
+		}
+		var ts, v float64
+		for _, item := range series.Data[i:end] {
+			ts += item.Timestamp
+			v += item.Value
+		}
+		cnt := float64(end - i)
+		data = append(data, DataItem{Timestamp: ts / cnt, Value: v / cnt})
+	}
+
+	series.Data = data
+
+	return series
+}
+
+func parseSeriesTime(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.Float64()
+	case string:
+		if f, err := strconv.ParseFloat(x, 64); err == nil {
+			return f, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, x)
+		if err != nil {
+			return 0, err
+		}
+		return DataItemAt(t, 0).Timestamp, nil
+	case nil:
+		return 0, fmt.Errorf("time is missing")
+	}
+	return 0, fmt.Errorf("unsupported time type %T", v)
+}
+
+func parseSeriesValue(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.Float64()
+	case string:
+		return strconv.ParseFloat(x, 64)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported value type %T", v)
+}
@@ -0,0 +1,147 @@
+package coreapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDataItemAt(t *testing.T) {
+	ts := time.Date(2022, 8, 15, 10, 0, 0, int(time.Millisecond*500), time.UTC)
+
+	d := DataItemAt(ts, 5)
+	if d.Timestamp != float64(ts.Unix())+0.5 || d.Value != 5 {
+		t.Fatalf("unexpected data item, got %+v", d)
+	}
+	if !d.Time().Equal(ts) {
+		t.Fatalf("unexpected time, got %s", d.Time())
+	}
+}
+
+func TestSeriesFromRows(t *testing.T) {
+	data := []byte(`[
+		{"ts":"2022-08-15T10:00:00Z","rps":10,"errors":"1","name":"a"},
+		{"ts":1660557660,"rps":12.5,"errors":null,"name":"a"}
+	]`)
+
+	series, err := SeriesFromRows(data, "ts", "rps", "errors")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(series) != 2 || series[0].Name != "rps" || series[1].Name != "errors" {
+		t.Fatalf("unexpected series, got %v", series)
+	}
+	if fmt.Sprint(series[0].Data) != "[{1.6605576e+09 10} {1.66055766e+09 12.5}]" {
+		t.Fatalf("unexpected rps data, got %v", series[0].Data)
+	}
+	if fmt.Sprint(series[1].Data) != "[{1.6605576e+09 1}]" {
+		t.Fatalf("unexpected errors data, got %v", series[1].Data)
+	}
+
+	series, err = SeriesFromRows([]byte(`[{"ts":1,"b":2,"a":true}]`), "ts")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(series) != 2 || series[0].Name != "a" || series[0].Data[0].Value != 1 || series[1].Name != "b" {
+		t.Fatalf("unexpected series, got %v", series)
+	}
+}
+
+func TestSeriesFromRows_error(t *testing.T) {
+	for data, expect := range map[string]string{
+		`bad`:                      "failed to unmarshal rows: invalid character 'b' looking for beginning of value",
+		`[{"v":1}]`:                "bad time column ts in row 0: time is missing",
+		`[{"ts":"x","v":1}]`:       `bad time column ts in row 0: parsing time "x" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "x" as "2006"`,
+		`[{"ts":true,"v":1}]`:      "bad time column ts in row 0: unsupported time type bool",
+		`[{"ts":1,"x":1}]`:         "column v is missing in row 0",
+		`[{"ts":1,"v":"x"}]`:       `bad value column v in row 0: strconv.ParseFloat: parsing "x": invalid syntax`,
+		`[{"ts":1,"v":{"a":"b"}}]`: "bad value column v in row 0: unsupported value type map[string]interface {}",
+	} {
+		_, err := SeriesFromRows([]byte(data), "ts", "v")
+		if err == nil {
+			t.Fatalf("expected error for %s, got nil", data)
+		}
+		if err.Error() != expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+}
+
+func TestSeriesFromPrometheusRange(t *testing.T) {
+	raw := `[{"metric":{"__name__":"up","job":"node","instance":"a"},"values":[[1660557600,"1"],[1660557615.5,"0"]]},` +
+		`{"metric":{"__name__":"up"},"values":[]},{"metric":{},"values":[[1,"2"]]}]`
+
+	for _, data := range []string{
+		raw,
+		`{"resultType":"matrix","result":` + raw + `}`,
+		`{"status":"success","data":{"resultType":"matrix","result":` + raw + `}}`,
+	} {
+		series, err := SeriesFromPrometheusRange([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if len(series) != 3 {
+			t.Fatalf("unexpected series length, got %d", len(series))
+		}
+		if series[0].Name != `up{instance="a", job="node"}` || fmt.Sprint(series[0].Data) != "[{1.6605576e+09 1} {1.6605576155e+09 0}]" {
+			t.Fatalf("unexpected series, got %v", series[0])
+		}
+		if series[1].Name != "up" || series[2].Name != "{}" {
+			t.Fatalf("unexpected series names, got %s, %s", series[1].Name, series[2].Name)
+		}
+	}
+}
+
+func TestSeriesFromLokiMatrix(t *testing.T) {
+	series, err := SeriesFromLokiMatrix([]byte(`{"resultType":"matrix","result":[{"stream":{"app":"api"},"values":[["1660557600","3"]]}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(series) != 1 || series[0].Name != `{app="api"}` || fmt.Sprint(series[0].Data) != "[{1.6605576e+09 3}]" {
+		t.Fatalf("unexpected series, got %v", series)
+	}
+}
+
+func TestSeriesFromMatrix_error(t *testing.T) {
+	for data, expect := range map[string]string{
+		`bad`:                                         "failed to unmarshal matrix: invalid character 'b' looking for beginning of value",
+		`{"resultType":"vector","result":[]}`:         `unexpected result type "vector", expect matrix`,
+		`{"resultType":"matrix","result":{}}`:         "failed to unmarshal matrix: json: cannot unmarshal object into Go value of type []coreapi.matrixSample",
+		`[{"metric":{"a":"b"},"values":[[1]]}]`:       `bad sample of {a="b"}: expect [timestamp, value]`,
+		`[{"metric":{"a":"b"},"values":[["x","1"]]}]`: `bad sample timestamp of {a="b"}: strconv.ParseFloat: parsing "x": invalid syntax`,
+		`[{"metric":{"a":"b"},"values":[[1,"x"]]}]`:   `bad sample value of {a="b"}: strconv.ParseFloat: parsing "x": invalid syntax`,
+	} {
+		_, err := SeriesFromPrometheusRange([]byte(data))
+		if err == nil {
+			t.Fatalf("expected error for %s, got nil", data)
+		}
+		if err.Error() != expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	s := DataSeries{Name: "s"}
+	for i := 0; i < 10; i++ {
+		s.Data = append(s.Data, DataItem{Timestamp: float64(i), Value: float64(i * 10)})
+	}
+
+	d := Downsample(s, 4)
+	if d.Name != "s" {
+		t.Fatalf("unexpected series name, got %s", d.Name)
+	}
+	if fmt.Sprint(d.Data) != "[{1 10} {4 40} {7 70} {9 90}]" {
+		t.Fatalf("unexpected data, got %v", d.Data)
+	}
+	if len(s.Data) != 10 {
+		t.Fatalf("the source series is changed")
+	}
+
+	if d := Downsample(s, 20); len(d.Data) != 10 {
+		t.Fatalf("unexpected data length, got %d", len(d.Data))
+	}
+	if d := Downsample(s, 0); len(d.Data) != 10 {
+		t.Fatalf("unexpected data length, got %d", len(d.Data))
+	}
+}
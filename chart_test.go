@@ -137,3 +137,70 @@ func TestModuleChart_Render(t *testing.T) {
 		t.Fatalf("unexpected response, got %s", resp)
 	}
 }
+
+func TestModuleChart_RenderWithOptions(t *testing.T) {
+	m := ModuleChart{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			if path != "chart/render" {
+				t.Fatalf("unexpected path value, got %s", path)
+			}
+			expect := `{"title":"t","series":[{"Color":"red","LineColor":"","PointColor":"","Data":null,"Name":"rps","LineStyle":"dashed","LineWidth":2}],` +
+				`"options":{"width":800,"height":400,"x_label":"time","y_label":"rps","unit":"req/s","y_min":0,"y_max":100,"legend":"bottom","format":"svg"}}`
+			if string(body) != expect {
+				t.Fatalf("unexpected body value, got %s", string(body))
+			}
+			return []byte(`"` + base64.StdEncoding.EncodeToString([]byte("<svg/>")) + `"`), nil
+		},
+	}
+
+	yMin, yMax := 0.0, 100.0
+
+	resp, err := m.RenderWithOptions("t", []DataSeries{{Color: "red", Name: "rps", LineStyle: LineStyleDashed, LineWidth: 2}}, &ChartOptions{
+		Width:  800,
+		Height: 400,
+		XLabel: "time",
+		YLabel: "rps",
+		Unit:   "req/s",
+		YMin:   &yMin,
+		YMax:   &yMax,
+		Legend: LegendBottom,
+		Format: ChartFormatSVG,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if string(resp) != "<svg/>" {
+		t.Fatalf("unexpected response, got %s", resp)
+	}
+}
+
+func TestModuleChart_RenderWithOptions_validate(t *testing.T) {
+	m := ModuleChart{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			t.Fatalf("unexpected call %s", path)
+			return nil, nil
+		},
+	}
+
+	yMin, yMax := 10.0, 10.0
+
+	for _, c := range []struct {
+		opts   *ChartOptions
+		series []DataSeries
+		expect string
+	}{
+		{opts: &ChartOptions{Width: -1}, expect: "chart size must not be negative"},
+		{opts: &ChartOptions{YMin: &yMin, YMax: &yMax}, expect: "chart y min must be less than y max"},
+		{opts: &ChartOptions{Legend: "left"}, expect: `unknown chart legend position "left"`},
+		{opts: &ChartOptions{Format: "gif"}, expect: `unknown chart format "gif"`},
+		{series: []DataSeries{{}, {LineStyle: "wavy"}}, expect: `unknown line style "wavy" of the series 1`},
+	} {
+		_, err := m.RenderWithOptions("t", c.series, c.opts)
+		if err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		if err.Error() != c.expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	collectionRecordsNamespace = "rec"
+	collectionIndexesNamespace = "idx"
+)
+
+// Collection is a tiny document store over the KV module. The records are stored as JSON in the collection
+// namespace. The secondary indexes are stored as separate keys, which are updated on Save and Delete.
+// The record and its index keys are not updated atomically.
+type Collection[T any] struct {
+	records ModuleKV
+	indexes ModuleKV
+
+	mu       sync.RWMutex
+	indexFns map[string]func(T) string
+}
+
+// NewCollection creates a new Collection with the name.
+func NewCollection[T any](kv ModuleKV, name string) *Collection[T] {
+	ns := kv.Namespace(name)
+	return &Collection[T]{
+		records:  ns.Namespace(collectionRecordsNamespace),
+		indexes:  ns.Namespace(collectionIndexesNamespace),
+		indexFns: map[string]func(T) string{},
+	}
+}
+
+// AddIndex adds the secondary index. fn returns the index value for the record, the empty value is not indexed.
+// The index is updated for the records, saved after it is added.
+// The index name should be a valid name without the separator, otherwise ErrInvalidName is returned.
+func (c *Collection[T]) AddIndex(name string, fn func(T) string) error {
+	if err := ValidateName(name); err != nil {
+		return fmt.Errorf("bad index: %w", err)
+	}
+	if strings.Contains(name, KVNamespaceSeparator) {
+		return fmt.Errorf("bad index: %w %q: name contains the separator %q", ErrInvalidName, name, KVNamespaceSeparator)
+	}
+
+	c.mu.Lock()
+	c.indexFns[name] = fn
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Save stores the record with the id and updates its index keys.
+func (c *Collection[T]) Save(id string, v T) error {
+	old, errOld := GetJSON[T](c.records, id)
+	if errOld != nil && !errors.Is(errOld, ErrKeyNotFound) {
+		return fmt.Errorf("failed to load record %s: %w", id, errOld)
+	}
+	hasOld := errOld == nil
+
+	if err := UpsertJSON(c.records, id, v); err != nil {
+		return fmt.Errorf("failed to save record %s: %w", id, err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for name, fn := range c.indexFns {
+		value := fn(v)
+		if hasOld {
+			if oldValue := fn(old); oldValue != "" && oldValue != value {
+				_ = c.indexes.Delete(indexKey(name, oldValue, id))
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if err := c.indexes.Upsert(indexKey(name, value, id), id); err != nil {
+			return fmt.Errorf("failed to save index %s of record %s: %w", name, id, err)
+		}
+	}
+
+	return nil
+}
+
+// Load returns the record with the id.
+func (c *Collection[T]) Load(id string) (T, error) {
+	return GetJSON[T](c.records, id)
+}
+
+// Delete deletes the record with the id and its index keys.
+func (c *Collection[T]) Delete(id string) error {
+	old, errOld := GetJSON[T](c.records, id)
+
+	if err := c.records.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete record %s: %w", id, err)
+	}
+
+	if errOld != nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for name, fn := range c.indexFns {
+		if value := fn(old); value != "" {
+			_ = c.indexes.Delete(indexKey(name, value, id))
+		}
+	}
+
+	return nil
+}
+
+// List returns all records by the id.
+func (c *Collection[T]) List() (map[string]T, error) {
+	all, err := c.records.All()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]T, len(all))
+	for id, s := range all {
+		var v T
+		if err := (JSONCodec{}).Unmarshal(s, &v); err != nil {
+			return nil, &DecodeError{Key: id, Value: s, Err: err}
+		}
+		result[id] = v
+	}
+
+	return result, nil
+}
+
+// IDs returns the sorted ids of all records.
+func (c *Collection[T]) IDs() ([]string, error) {
+	all, err := c.records.All()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// FindBy returns the records with the index value by the id.
+// The index keys of the missing records, e.g. left by the interrupted Delete, and the stale index keys
+// of the records with other index value are skipped.
+func (c *Collection[T]) FindBy(index, value string) (map[string]T, error) {
+	c.mu.RLock()
+	fn, ok := c.indexFns[index]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown index %s", index)
+	}
+
+	all, err := c.indexes.All()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := indexKey(index, value, "")
+
+	result := map[string]T{}
+	for k, id := range all {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		v, err := c.Load(id)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load record %s: %w", id, err)
+		}
+		if fn(v) != value {
+			continue
+		}
+		result[id] = v
+	}
+
+	return result, nil
+}
+
+// indexKey returns the index key. The index value is escaped, so it does not contain the separator.
+func indexKey(index, value, id string) string {
+	return index + KVNamespaceSeparator + url.QueryEscape(value) + KVNamespaceSeparator + id
+}
@@ -0,0 +1,215 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type collectionHost struct {
+	Owner    string    `json:"owner"`
+	Status   string    `json:"status"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func TestCollection(t *testing.T) {
+	kv := newFakeKV()
+
+	c := NewCollection[collectionHost](kv.module(), "hosts")
+	if err := c.AddIndex("owner", func(h collectionHost) string { return h.Owner }); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	ts := time.Date(2022, 8, 15, 10, 0, 0, 0, time.UTC)
+
+	if err := c.Save("h1", collectionHost{Owner: "team:a", Status: "up", LastSeen: ts}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := c.Save("h2", collectionHost{Owner: "team:a", Status: "down"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := c.Save("h3", collectionHost{Owner: "team:a:b"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if kv.data["hosts:idx:owner:team%3Aa:h1"] != "h1" {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+
+	h, err := c.Load("h1")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if h.Owner != "team:a" || h.Status != "up" || !h.LastSeen.Equal(ts) {
+		t.Fatalf("unexpected record, got %+v", h)
+	}
+
+	found, err := c.FindBy("owner", "team:a")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(found) != 2 || found["h2"].Status != "down" {
+		t.Fatalf("unexpected found records, got %v", found)
+	}
+
+	// the index is updated on save
+	if err := c.Save("h2", collectionHost{Owner: "team:c"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	found, err = c.FindBy("owner", "team:a")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("unexpected found records, got %v", found)
+	}
+
+	ids, err := c.IDs()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if fmt.Sprint(ids) != "[h1 h2 h3]" {
+		t.Fatalf("unexpected ids, got %v", ids)
+	}
+
+	list, err := c.List()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(list) != 3 || list["h2"].Owner != "team:c" {
+		t.Fatalf("unexpected records, got %v", list)
+	}
+
+	if err := c.Delete("h1"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, err := c.Load("h1"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if _, ok := kv.data["hosts:idx:owner:team%3Aa:h1"]; ok {
+		t.Fatalf("the index key is not deleted, got %v", kv.data)
+	}
+	if len(kv.data) != 4 {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+}
+
+func TestCollection_errors(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["c:rec:bad"] = "bad"
+
+	c := NewCollection[collectionHost](kv.module(), "c")
+
+	_, err := c.List()
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %v", err)
+	}
+
+	_, err = c.FindBy("missing", "v")
+	if err == nil || err.Error() != "unknown index missing" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.Delete("missing"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	c = NewCollection[collectionHost](ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}, "c")
+
+	err = c.Save("id", collectionHost{})
+	if err == nil || err.Error() != "failed to load record id: err1" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, err := c.List(); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if _, err := c.IDs(); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	c = NewCollection[collectionHost](ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path == "kv/get/c:rec:id" {
+			return nil, fmt.Errorf("variable not exists")
+		}
+		return nil, fmt.Errorf("err1")
+	}}, "c")
+
+	err = c.Save("id", collectionHost{})
+	if err == nil || err.Error() != "failed to save record id: err1" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestCollection_FindBy_dangling(t *testing.T) {
+	kv := newFakeKV()
+
+	c := NewCollection[collectionHost](kv.module(), "hosts")
+	if err := c.AddIndex("owner", func(h collectionHost) string { return h.Owner }); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.Save("h1", collectionHost{Owner: "a"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	// the index key of the record, deleted without the index update
+	kv.data["hosts:idx:owner:a:h2"] = "h2"
+
+	found, err := c.FindBy("owner", "a")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(found) != 1 || found["h1"].Owner != "a" {
+		t.Fatalf("unexpected found records, got %v", found)
+	}
+}
+
+func TestCollection_FindBy_stale(t *testing.T) {
+	kv := newFakeKV()
+
+	c := NewCollection[collectionHost](kv.module(), "hosts")
+	if err := c.AddIndex("owner", func(h collectionHost) string { return h.Owner }); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.Save("h1", collectionHost{Owner: "a"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	// the old index key, left by the interrupted Save
+	kv.data["hosts:rec:h1"] = `{"owner":"b"}`
+	kv.data["hosts:idx:owner:b:h1"] = "h1"
+
+	found, err := c.FindBy("owner", "a")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("unexpected found records, got %v", found)
+	}
+
+	found, err = c.FindBy("owner", "b")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(found) != 1 || found["h1"].Owner != "b" {
+		t.Fatalf("unexpected found records, got %v", found)
+	}
+}
+
+func TestCollection_AddIndex_error(t *testing.T) {
+	c := NewCollection[collectionHost](newFakeKV().module(), "hosts")
+
+	for _, name := range []string{"", "a:b"} {
+		err := c.AddIndex(name, func(h collectionHost) string { return h.Owner })
+		if !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected ErrInvalidName for %q, got %v", name, err)
+		}
+	}
+
+	if _, err := c.FindBy("a:b", "c"); err == nil || err.Error() != "unknown index a:b" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
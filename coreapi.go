@@ -3,11 +3,15 @@ package coreapi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 )
 
+// ErrNotSupported is returned, if the balerter server does not support the called method.
+var ErrNotSupported = errors.New("method is not supported by the balerter server")
+
 type apiResponse struct {
 	Status string          `json:"status"`
 	Error  string          `json:"error,omitempty"`
@@ -53,7 +57,7 @@ func New(address, authToken string) *Balerter {
 	}
 	c.Alert = ModuleAlert{rf: c.request}
 	c.Datasource = ModuleDatasource{rf: c.request}
-	c.KV = ModuleKV{rf: c.request}
+	c.KV = ModuleKV{rf: c.request, ops: &kvServerOps{}}
 	c.Log = ModuleLog{rf: c.request}
 	c.TLS = ModuleTLS{rf: c.request}
 	c.Runtime = ModuleRuntime{rf: c.request}
@@ -86,6 +90,9 @@ func (b *Balerter) request(path, contentType string, body []byte) ([]byte, error
 	r := apiResponse{}
 
 	errRead := json.NewDecoder(resp.Body).Decode(&r)
+	if errRead != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed) {
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+	}
 	if errRead != nil {
 		return nil, fmt.Errorf("error decode response, %w", errRead)
 	}
@@ -1,6 +1,7 @@
 package coreapi
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -135,3 +136,30 @@ func TestCoreAPI_request(t *testing.T) {
 		t.Errorf("unexpected response, got %s", string(resp))
 	}
 }
+
+func TestCoreAPI_request_error_not_supported(t *testing.T) {
+	cl := &httpClientMock{
+		do: func(req *http.Request) (*http.Response, error) {
+			resp := &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader(`404 page not found`)),
+			}
+			return resp, nil
+		},
+	}
+
+	m := Balerter{
+		client: cl,
+	}
+
+	_, err := m.request("foo", "text", []byte("body"))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if err.Error() != "method is not supported by the balerter server: foo" {
+		t.Errorf("unexpected error value, got %s", err.Error())
+	}
+}
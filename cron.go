@@ -0,0 +1,158 @@
+package coreapi
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with five fields: minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are true, if the field is the '*' wildcard
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// parseCron parses the cron expression, e.g. "0 22 * * 1-5"
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expr, len(cronFields))
+	}
+
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+
+	// 7 is the Sunday as well as 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad %s step %q", f.name, part)
+			}
+		}
+
+		from, to := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("bad %s value %q", f.name, part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("bad %s value %q", f.name, part)
+				}
+			} else if step > 1 {
+				to = f.max
+			}
+		}
+
+		if from < f.min || to > f.max || from > to {
+			return 0, fmt.Errorf("%s value %q is out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// matches returns true, if the schedule fires at the minute of the time
+func (c *cronSchedule) matches(t time.Time) bool {
+	return c.minute&(1<<uint(t.Minute())) != 0 && c.hour&(1<<uint(t.Hour())) != 0 &&
+		c.month&(1<<uint(t.Month())) != 0 && c.dayMatches(t)
+}
+
+// dayMatches returns true, if the schedule fires at the day of the time
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+
+	// if both day fields are restricted, the time should match any of them
+	if !c.domStar && !c.dowStar {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+// prev returns the latest fire time, which is not after the time and is after the bound.
+// The non-matching month, day and hour are skipped at once.
+func (c *cronSchedule) prev(t, bound time.Time) (time.Time, bool) {
+	ts := t.Truncate(time.Minute)
+	for ts.After(bound) {
+		loc := ts.Location()
+		switch {
+		case c.month&(1<<uint(ts.Month())) == 0:
+			ts = time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, loc).Add(-time.Minute)
+		case !c.dayMatches(ts):
+			ts = time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, loc).Add(-time.Minute)
+		case c.hour&(1<<uint(ts.Hour())) == 0:
+			ts = ts.Add(-time.Duration(ts.Minute()+1) * time.Minute)
+		default:
+			m := ts.Minute()
+			mask := c.minute & (1<<uint(m+1) - 1)
+			if mask == 0 {
+				ts = ts.Add(-time.Duration(m+1) * time.Minute)
+				continue
+			}
+			ts = ts.Add(-time.Duration(m-(bits.Len64(mask)-1)) * time.Minute)
+			return ts, ts.After(bound)
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeWindow returns true, if the schedule fired within the duration before the time
+func (c *cronSchedule) activeWindow(t time.Time, d time.Duration) bool {
+	_, ok := c.prev(t, t.Add(-d))
+	return ok
+}
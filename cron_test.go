@@ -0,0 +1,130 @@
+package coreapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_error(t *testing.T) {
+	for expr, expect := range map[string]string{
+		"* * * *":       `cron expression "* * * *" must have 5 fields`,
+		"60 * * * *":    `cron expression "60 * * * *": minute value "60" is out of range 0-59`,
+		"* x * * *":     `cron expression "* x * * *": bad hour value "x"`,
+		"* * 0 * *":     `cron expression "* * 0 * *": day of month value "0" is out of range 1-31`,
+		"*/0 * * * *":   `cron expression "*/0 * * * *": bad minute step "*/0"`,
+		"* * * 5-2 *":   `cron expression "* * * 5-2 *": month value "5-2" is out of range 1-12`,
+		"* * * * 1-x":   `cron expression "* * * * 1-x": bad day of week value "1-x"`,
+		"* * * * 1,2,8": `cron expression "* * * * 1,2,8": day of week value "8" is out of range 0-7`,
+	} {
+		_, err := parseCron(expr)
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", expr)
+		}
+		if err.Error() != expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+}
+
+func TestCronSchedule_matches(t *testing.T) {
+	// 2022-08-15 is Monday
+	ts := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		return v
+	}
+
+	for _, c := range []struct {
+		expr   string
+		t      string
+		expect bool
+	}{
+		{expr: "* * * * *", t: "2022-08-15 10:11", expect: true},
+		{expr: "0 22 * * 1-5", t: "2022-08-15 22:00", expect: true},
+		{expr: "0 22 * * 1-5", t: "2022-08-14 22:00", expect: false},
+		{expr: "0 22 * * 7", t: "2022-08-14 22:00", expect: true},
+		{expr: "*/15 * * * *", t: "2022-08-15 10:45", expect: true},
+		{expr: "*/15 * * * *", t: "2022-08-15 10:46", expect: false},
+		{expr: "5/10 * * * *", t: "2022-08-15 10:35", expect: true},
+		{expr: "0 0 1 * 1", t: "2022-08-15 00:00", expect: true},
+		{expr: "0 0 1 * 1", t: "2022-08-16 00:00", expect: false},
+		{expr: "0 0 1,15 8 *", t: "2022-08-15 00:00", expect: true},
+		{expr: "0 0 1,15 9 *", t: "2022-08-15 00:00", expect: false},
+	} {
+		s, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if s.matches(ts(c.t)) != c.expect {
+			t.Fatalf("unexpected match result for %q at %s, expect %v", c.expr, c.t, c.expect)
+		}
+	}
+}
+
+func TestCronSchedule_activeWindow(t *testing.T) {
+	s, err := parseCron("0 22 * * *")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	start := time.Date(2022, 8, 15, 22, 0, 0, 0, time.UTC)
+
+	if !s.activeWindow(start.Add(time.Minute*30), time.Hour) {
+		t.Fatalf("expected active window")
+	}
+	if s.activeWindow(start.Add(time.Hour), time.Hour) {
+		t.Fatalf("expected inactive window")
+	}
+	if s.activeWindow(start.Add(-time.Minute), time.Hour) {
+		t.Fatalf("expected inactive window")
+	}
+}
+
+func TestCronSchedule_prev(t *testing.T) {
+	locations := []*time.Location{time.UTC}
+	if loc, err := time.LoadLocation("America/New_York"); err == nil {
+		locations = append(locations, loc)
+	}
+
+	// activeWindowScan is the reference implementation, which checks every minute of the window
+	activeWindowScan := func(c *cronSchedule, t time.Time, d time.Duration) bool {
+		for ts := t.Truncate(time.Minute); t.Sub(ts) < d; ts = ts.Add(-time.Minute) {
+			if c.matches(ts) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, expr := range []string{"* * * * *", "0 22 * * 1-5", "30 2 * * *", "*/20 9-17 1,15 * 0", "59 23 31 12 *", "0 0 29 2 *"} {
+		c, err := parseCron(expr)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		for _, loc := range locations {
+			start := time.Date(2022, 3, 12, 0, 7, 30, 0, loc)
+			for ts := start; ts.Before(start.Add(time.Hour * 24 * 3)); ts = ts.Add(time.Minute * 17) {
+				for _, d := range []time.Duration{time.Minute, time.Hour, time.Hour * 30} {
+					if c.activeWindow(ts, d) != activeWindowScan(c, ts, d) {
+						t.Fatalf("unexpected active window for %q at %s for %s", expr, ts, d)
+					}
+				}
+			}
+		}
+	}
+
+	c, err := parseCron("30 22 * * *")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	now := time.Date(2022, 8, 15, 10, 0, 0, 0, time.UTC)
+	p, ok := c.prev(now, now.Add(-time.Hour*24*365))
+	if !ok || !p.Equal(time.Date(2022, 8, 14, 22, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected prev value, got %s, %v", p, ok)
+	}
+	if _, ok := c.prev(now, now.Add(-time.Hour)); ok {
+		t.Fatalf("unexpected prev within the bound")
+	}
+}
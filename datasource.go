@@ -24,7 +24,11 @@ type ModuleDatasourceMySQL struct {
 
 // Query method for the mysql datasource.
 func (m ModuleDatasourceMySQL) Query(query string) ([]byte, error) {
-	resp, err := m.rf("datasource/mysql/"+m.name+"/query", "text/plain", []byte(query))
+	name, errName := escapeName(m.name)
+	if errName != nil {
+		return nil, errName
+	}
+	resp, err := m.rf("datasource/mysql/"+name+"/query", "text/plain", []byte(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to call mysql.query: %w", err)
 	}
@@ -65,7 +69,11 @@ func (p *LokiQueryParams) toQuery() string {
 
 // Query method for the loki datasource.
 func (m ModuleDatasourceLoki) Query(query string, params *LokiQueryParams) ([]byte, error) {
-	u := "datasource/loki/" + m.name + "/query"
+	name, errName := escapeName(m.name)
+	if errName != nil {
+		return nil, errName
+	}
+	u := "datasource/loki/" + name + "/query"
 	if params != nil {
 		u += "?" + params.toQuery()
 	}
@@ -106,7 +114,11 @@ func (p *LokiRangeParams) toQuery() string {
 
 // Range method for the loki datasource.
 func (m ModuleDatasourceLoki) Range(query string, params *LokiRangeParams) ([]byte, error) {
-	u := "datasource/loki/" + m.name + "/range"
+	name, errName := escapeName(m.name)
+	if errName != nil {
+		return nil, errName
+	}
+	u := "datasource/loki/" + name + "/range"
 	if params != nil {
 		u += "?" + params.toQuery()
 	}
@@ -131,7 +143,11 @@ type ModuleDatasourcePostgres struct {
 
 // Query method for the postgres datasource.
 func (m ModuleDatasourcePostgres) Query(query string) ([]byte, error) {
-	resp, err := m.rf("datasource/postgres/"+m.name+"/query", "text/plain", []byte(query))
+	name, errName := escapeName(m.name)
+	if errName != nil {
+		return nil, errName
+	}
+	resp, err := m.rf("datasource/postgres/"+name+"/query", "text/plain", []byte(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to call postgres.query: %w", err)
 	}
@@ -152,7 +168,11 @@ type ModuleDatasourceClickhouse struct {
 
 // Query method for the clickhouse datasource.
 func (m ModuleDatasourceClickhouse) Query(query string) ([]byte, error) {
-	resp, err := m.rf("datasource/clickhouse/"+m.name+"/query", "text/plain", []byte(query))
+	name, errName := escapeName(m.name)
+	if errName != nil {
+		return nil, errName
+	}
+	resp, err := m.rf("datasource/clickhouse/"+name+"/query", "text/plain", []byte(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to call clickhouse.query: %w", err)
 	}
@@ -2,36 +2,121 @@ package coreapi
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
+// KVNamespaceSeparator separates the namespace and the key in the stored key.
+const KVNamespaceSeparator = ":"
+
+// ErrKeyNotFound is returned by Get for the missing key. It has the text of the balerter server error.
+var ErrKeyNotFound = errors.New("variable not exists")
+
 type ModuleKV struct {
-	rf requestFunc
+	rf     requestFunc
+	prefix string
+	// errPrefix is returned by all operations, if the namespace name is invalid
+	errPrefix error
+	// ops caches the unsupported server-side atomic operations
+	ops *kvServerOps
+}
+
+// Namespace returns the KV module scoped with the namespace.
+// The keys are transparently prefixed with the namespace and the separator, e.g. "team-a:key".
+// Namespaces may be nested.
+//
+// The name should be a valid name without the separator, so the nested namespaces do not collide
+// with the names, containing the separator. Otherwise, all operations of the namespace fail with ErrInvalidName.
+func (kv ModuleKV) Namespace(name string) ModuleKV {
+	if kv.errPrefix == nil {
+		kv.errPrefix = validateNamespace(name)
+	}
+	kv.prefix += name + KVNamespaceSeparator
+	return kv
+}
+
+func validateNamespace(name string) error {
+	if err := ValidateName(name); err != nil {
+		return fmt.Errorf("bad namespace: %w", err)
+	}
+	if strings.Contains(name, KVNamespaceSeparator) {
+		return fmt.Errorf("bad namespace: %w %q: name contains the separator %q", ErrInvalidName, name, KVNamespaceSeparator)
+	}
+	return nil
 }
 
-// Put the value to the storage
+// key validates the key and escapes it with the namespace prefix as the request path segment
+func (kv ModuleKV) key(key string) (string, error) {
+	if kv.errPrefix != nil {
+		return "", kv.errPrefix
+	}
+	return escapeName(kv.prefix + key)
+}
+
+// Prefix returns the full key prefix of the namespace. It is empty for the root KV module.
+func (kv ModuleKV) Prefix() string {
+	return kv.prefix
+}
+
+// Put the value to the storage. It fails on the existing key, even if the key is expired, see PutWithTTL.
+// Use SetIfAbsent to replace the expired key.
 func (kv ModuleKV) Put(key, value string) error {
-	_, err := kv.rf("kv/put/"+key, "text/plain", []byte(value))
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return errKey
+	}
+	_, err := kv.rf("kv/put/"+k, "text/plain", []byte(value))
 	return err
 }
 
 // Upsert the value in the storage
 func (kv ModuleKV) Upsert(key, value string) error {
-	_, err := kv.rf("kv/upsert/"+key, "text/plain", []byte(value))
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return errKey
+	}
+	_, err := kv.rf("kv/upsert/"+k, "text/plain", []byte(value))
 	return err
 }
 
 // Delete the value from the storage
 func (kv ModuleKV) Delete(key string) error {
-	_, err := kv.rf("kv/delete/"+key, "", nil)
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return errKey
+	}
+	_, err := kv.rf("kv/delete/"+k, "", nil)
 	return err
 }
 
-// Get a value from the storage
+// Get a value from the storage. The expired value is reported with ErrKeyExpired.
 func (kv ModuleKV) Get(key string) (string, error) {
-	resp, err := kv.rf("kv/get/"+key, "", nil)
+	raw, err := kv.getRaw(key)
 	if err != nil {
 		return "", err
 	}
+	value, expires, ok := decodeTTLValue(raw)
+	if ok && !time.Now().Before(expires) {
+		return "", fmt.Errorf("%w: %s", ErrKeyExpired, key)
+	}
+	return value, nil
+}
+
+// getRaw returns the stored value as is, without the expiry check
+func (kv ModuleKV) getRaw(key string) (string, error) {
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return "", errKey
+	}
+	resp, err := kv.rf("kv/get/"+k, "", nil)
+	if err != nil {
+		if err.Error() == ErrKeyNotFound.Error() {
+			return "", ErrKeyNotFound
+		}
+		return "", err
+	}
 	var result string
 	errUnmarshal := json.Unmarshal(resp, &result)
 	if errUnmarshal != nil {
@@ -40,8 +125,33 @@ func (kv ModuleKV) Get(key string) (string, error) {
 	return result, nil
 }
 
-// All returns all the values from the storage
+// All returns all the values from the storage. For the namespace, only its keys are returned without the prefix.
+// The expired values are skipped.
 func (kv ModuleKV) All() (map[string]string, error) {
+	result, err := kv.allRaw()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for k, v := range result {
+		value, expires, ok := decodeTTLValue(v)
+		if !ok {
+			continue
+		}
+		if now.Before(expires) {
+			result[k] = value
+		} else {
+			delete(result, k)
+		}
+	}
+	return result, nil
+}
+
+// allRaw returns the stored values of the namespace as is, without the expiry check
+func (kv ModuleKV) allRaw() (map[string]string, error) {
+	if kv.errPrefix != nil {
+		return nil, kv.errPrefix
+	}
 	resp, err := kv.rf("kv/all", "", nil)
 	if err != nil {
 		return nil, err
@@ -51,5 +161,14 @@ func (kv ModuleKV) All() (map[string]string, error) {
 	if errUnmarshal != nil {
 		return nil, errUnmarshal
 	}
-	return result, nil
+	if kv.prefix == "" {
+		return result, nil
+	}
+	scoped := map[string]string{}
+	for k, v := range result {
+		if strings.HasPrefix(k, kv.prefix) {
+			scoped[strings.TrimPrefix(k, kv.prefix)] = v
+		}
+	}
+	return scoped, nil
 }
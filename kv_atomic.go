@@ -0,0 +1,377 @@
+package coreapi
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// kvLockPrefix is the prefix of the keys, used for the key locks in the client-side atomic operations
+	kvLockPrefix = "__lock" + KVNamespaceSeparator
+	kvLockTTL    = time.Second * 10
+	kvLockWait   = time.Second * 30
+
+	kvLockMinBackoff = time.Millisecond
+	kvLockMaxBackoff = time.Millisecond * 100
+)
+
+// ErrKeyLockTimeout is returned, if the key lock for the atomic operation is not acquired in time.
+var ErrKeyLockTimeout = errors.New("key lock timeout")
+
+// kvServerOps caches the unsupported server-side atomic operations, so they are not requested on every call.
+// It is shared by the module copies, e.g. the namespaces. The nil kvServerOps does not cache.
+type kvServerOps struct {
+	noCAS       int32
+	noIncrement int32
+}
+
+func (o *kvServerOps) casSupported() bool {
+	return o == nil || atomic.LoadInt32(&o.noCAS) == 0
+}
+
+func (o *kvServerOps) setNoCAS() {
+	if o != nil {
+		atomic.StoreInt32(&o.noCAS, 1)
+	}
+}
+
+func (o *kvServerOps) incrementSupported() bool {
+	return o == nil || atomic.LoadInt32(&o.noIncrement) == 0
+}
+
+func (o *kvServerOps) setNoIncrement() {
+	if o != nil {
+		atomic.StoreInt32(&o.noIncrement, 1)
+	}
+}
+
+// SetIfAbsent puts the value to the storage, if the key does not exist or is expired, see PutWithTTL.
+// It returns false, if the key already exists.
+func (kv ModuleKV) SetIfAbsent(key, value string) (bool, error) {
+	errPut := kv.Put(key, value)
+	if errPut == nil {
+		return true, nil
+	}
+
+	current, errGet := kv.getRaw(key)
+	if errGet != nil {
+		return false, errPut
+	}
+
+	return kv.replaceExpired(key, current, value)
+}
+
+// create puts the value, if the key does not exist. Put fails on the existing key,
+// so the key is checked to distinguish it from other errors. It is used for the key locks,
+// so the expired keys are not replaced: CompareAndSwap may take the key lock itself.
+func (kv ModuleKV) create(key, value string) (bool, error) {
+	errPut := kv.Put(key, value)
+	if errPut == nil {
+		return true, nil
+	}
+
+	if _, errGet := kv.getRaw(key); errGet == nil {
+		return false, nil
+	}
+
+	return false, errPut
+}
+
+// CompareAndSwap sets the new value, if the current value of the key is equal to the old one.
+// It returns false, if the current value is not equal to the old one. The key should exist.
+//
+// The server-side operation is used, if the balerter server supports it. Otherwise, the operation is
+// performed under the key lock, stored in the KV, and the server-side operation is not requested again. In this case, it is atomic only among the clients, which use
+// CompareAndSwap and Increment, but not Put, Upsert or Delete, for the key.
+//
+// The value with the expiry time, see PutWithTTL, is compared without the expiry time as Get returns it,
+// and the new value keeps the expiry time. The expired value is not swapped.
+func (kv ModuleKV) CompareAndSwap(key, old, value string) (bool, error) {
+	swapped, err := kv.compareAndSwapRaw(key, old, value)
+	if err != nil || swapped {
+		return swapped, err
+	}
+
+	current, errGet := kv.getRaw(key)
+	if errGet != nil {
+		return false, errGet
+	}
+	decoded, expires, ok := decodeTTLValue(current)
+	if !ok || decoded != old || !time.Now().Before(expires) {
+		return false, nil
+	}
+
+	return kv.compareAndSwapRaw(key, current, encodeTTLValue(value, expires))
+}
+
+// compareAndSwapRaw compares and swaps the stored values as is, without the expiry time decoding
+func (kv ModuleKV) compareAndSwapRaw(key, old, value string) (bool, error) {
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return false, errKey
+	}
+
+	if kv.ops.casSupported() {
+		payload, errMarshal := json.Marshal(struct {
+			Old string `json:"old"`
+			New string `json:"new"`
+		}{Old: old, New: value})
+		if errMarshal != nil {
+			return false, fmt.Errorf("request marshal error, %w", errMarshal)
+		}
+
+		resp, err := kv.rf("kv/cas/"+k, "application/json", payload)
+		if err == nil {
+			var swapped bool
+			if errUnmarshal := json.Unmarshal(resp, &swapped); errUnmarshal != nil {
+				return false, fmt.Errorf("failed to unmarshal response: %w", errUnmarshal)
+			}
+			return swapped, nil
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			return false, err
+		}
+		kv.ops.setNoCAS()
+	}
+
+	var swapped bool
+
+	err := kv.withKeyLock(key, func() error {
+		current, errGet := kv.getRaw(key)
+		if errGet != nil {
+			return errGet
+		}
+		if current != old {
+			return nil
+		}
+		if errUpsert := kv.Upsert(key, value); errUpsert != nil {
+			return errUpsert
+		}
+		swapped = true
+		return nil
+	})
+
+	return swapped, err
+}
+
+// Increment adds the delta to the integer value of the key and returns the new value.
+// The missing or expired key is created with the delta value. The value with the expiry time keeps it.
+//
+// The server-side operation is used, if the balerter server supports it, see CompareAndSwap.
+func (kv ModuleKV) Increment(key string, delta int64) (int64, error) {
+	k, errKey := kv.key(key)
+	if errKey != nil {
+		return 0, errKey
+	}
+
+	if kv.ops.incrementSupported() {
+		resp, err := kv.rf("kv/increment/"+k, "text/plain", []byte(strconv.FormatInt(delta, 10)))
+		if err == nil {
+			var v int64
+			if errUnmarshal := json.Unmarshal(resp, &v); errUnmarshal != nil {
+				return 0, fmt.Errorf("failed to unmarshal response: %w", errUnmarshal)
+			}
+			return v, nil
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			// the server-side operation fails on the value with the expiry time, which is not an integer
+			return kv.incrementTTL(key, delta, err)
+		}
+		kv.ops.setNoIncrement()
+	}
+
+	created, err := kv.SetIfAbsent(key, strconv.FormatInt(delta, 10))
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		return delta, nil
+	}
+
+	var result int64
+
+	err = kv.withKeyLock(key, func() error {
+		raw, errGet := kv.getRaw(key)
+		if errGet != nil {
+			return errGet
+		}
+		value, expires, ok := decodeTTLValue(raw)
+		if ok && !time.Now().Before(expires) {
+			result = delta
+			return kv.Upsert(key, strconv.FormatInt(result, 10))
+		}
+		current, errParse := strconv.ParseInt(value, 10, 64)
+		if errParse != nil {
+			return &DecodeError{Key: key, Value: value, Err: errParse}
+		}
+		result = current + delta
+		if ok {
+			return kv.Upsert(key, encodeTTLValue(strconv.FormatInt(result, 10), expires))
+		}
+		return kv.Upsert(key, strconv.FormatInt(result, 10))
+	})
+
+	return result, err
+}
+
+// incrementTTL increments the value with the expiry time with CompareAndSwap and keeps the expiry time.
+// The expired value is replaced with the delta. errIncrement is returned, if the value has no expiry time.
+func (kv ModuleKV) incrementTTL(key string, delta int64, errIncrement error) (int64, error) {
+	for {
+		raw, errGet := kv.getRaw(key)
+		if errGet != nil {
+			return 0, errIncrement
+		}
+		value, expires, ok := decodeTTLValue(raw)
+		if !ok {
+			return 0, errIncrement
+		}
+
+		result := delta
+		next := strconv.FormatInt(result, 10)
+		if time.Now().Before(expires) {
+			current, errParse := strconv.ParseInt(value, 10, 64)
+			if errParse != nil {
+				return 0, &DecodeError{Key: key, Value: value, Err: errParse}
+			}
+			result = current + delta
+			next = encodeTTLValue(strconv.FormatInt(result, 10), expires)
+		}
+
+		swapped, err := kv.compareAndSwapRaw(key, raw, next)
+		if err != nil {
+			return 0, err
+		}
+		if swapped {
+			return result, nil
+		}
+	}
+}
+
+// withKeyLock calls fn under the key lock. The lock is the KV key, which is created with Put.
+//
+// The KV module has no conditional write except Put, which creates the missing key only. An optimistic
+// read-verify-retry loop can not detect a concurrent write of the same value, e.g. two increments from 5 to 6
+// both verify 6, so the operations are serialized with the lock instead.
+//
+// The lock value is the unique owner token and the expiry time, so the lock of the crashed client is taken over
+// after kvLockTTL, see takeOverKeyLock. The lock is released only if it still has the owner token.
+// fn must complete within kvLockTTL, otherwise the lock may be taken over by another client.
+func (kv ModuleKV) withKeyLock(key string, fn func() error) error {
+	lockKey := kvLockPrefix + key
+	deadline := time.Now().Add(kvLockWait)
+	backoff := kvLockMinBackoff
+
+	var lock string
+	for {
+		lock = newKeyLockValue()
+		acquired, err := kv.create(lockKey, lock)
+		if err != nil {
+			return fmt.Errorf("failed to acquire key lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+
+		if current, errGet := kv.getRaw(lockKey); errGet == nil && keyLockExpired(current) {
+			taken, errTake := kv.takeOverKeyLock(lockKey, current, lock)
+			if errTake != nil {
+				return fmt.Errorf("failed to take over key lock: %w", errTake)
+			}
+			if taken {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s", ErrKeyLockTimeout, key)
+		}
+
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff))))
+		if backoff *= 2; backoff > kvLockMaxBackoff {
+			backoff = kvLockMaxBackoff
+		}
+	}
+
+	defer kv.releaseKeyLock(lockKey, lock)
+
+	return fn()
+}
+
+// takeOverKeyLock replaces the expired lock value stale with the lock value.
+// Only one client replaces the stale value: the takeover is guarded by the marker key for the stale token,
+// which is created with Put. The marker of the client, crashed during the takeover, expires after kvLockTTL,
+// then the next marker generation is used. The marker is deleted by its creator only.
+func (kv ModuleKV) takeOverKeyLock(lockKey, stale, lock string) (bool, error) {
+	token, _ := parseKeyLockValue(stale)
+
+	for gen := 0; ; gen++ {
+		marker := lockKey + KVNamespaceSeparator + "takeover" + KVNamespaceSeparator + token + KVNamespaceSeparator + strconv.Itoa(gen)
+		created, err := kv.create(marker, newKeyLockValue())
+		if err != nil {
+			return false, err
+		}
+		if !created {
+			current, errGet := kv.getRaw(marker)
+			if errGet != nil || !keyLockExpired(current) {
+				// another client takes over the lock
+				return false, nil
+			}
+			continue
+		}
+
+		taken := false
+		current, errGet := kv.getRaw(lockKey)
+		if errGet == nil && current == stale {
+			if errUpsert := kv.Upsert(lockKey, lock); errUpsert != nil {
+				_ = kv.Delete(marker)
+				return false, errUpsert
+			}
+			taken = true
+		}
+		_ = kv.Delete(marker)
+
+		return taken, nil
+	}
+}
+
+// releaseKeyLock deletes the lock, if it is still held with the lock value
+func (kv ModuleKV) releaseKeyLock(lockKey, lock string) {
+	if current, err := kv.getRaw(lockKey); err == nil && current == lock {
+		_ = kv.Delete(lockKey)
+	}
+}
+
+// newKeyLockValue returns the lock value with the unique owner token and the expiry time in unix nanoseconds
+func newKeyLockValue() string {
+	b := make([]byte, 8)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b) + KVNamespaceSeparator + strconv.FormatInt(time.Now().Add(kvLockTTL).UnixNano(), 10)
+}
+
+// parseKeyLockValue returns the owner token and the expiry time of the lock value
+func parseKeyLockValue(v string) (string, time.Time) {
+	token, expiry := v, v
+	if i := strings.LastIndex(v, KVNamespaceSeparator); i >= 0 {
+		token, expiry = v[:i], v[i+1:]
+	}
+	ns, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return token, time.Time{}
+	}
+	return token, time.Unix(0, ns)
+}
+
+// keyLockExpired reports whether the lock value is expired. The unparsable value is never expired.
+func keyLockExpired(v string) bool {
+	_, expires := parseKeyLockValue(v)
+	return !expires.IsZero() && time.Now().After(expires)
+}
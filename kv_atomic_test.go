@@ -0,0 +1,425 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestModuleKV_SetIfAbsent(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+
+	ok, err := m.SetIfAbsent("k", "v1")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected set value")
+	}
+
+	ok, err = m.SetIfAbsent("k", "v2")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected set value")
+	}
+	if kv.data["k"] != "v1" {
+		t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+	}
+}
+
+func TestModuleKV_SetIfAbsent_expired(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+	kv.data["k"] = encodeTTLValue("v1", time.Now().Add(-time.Second))
+	kv.data["live"] = encodeTTLValue("v1", time.Now().Add(time.Hour))
+
+	ok, err := m.SetIfAbsent("k", "v2")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected set value")
+	}
+	if kv.data["k"] != "v2" {
+		t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+	}
+
+	ok, err = m.SetIfAbsent("live", "v2")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected set value")
+	}
+
+	// the expired counter is restarted
+	kv.data["c"] = encodeTTLValue("5", time.Now().Add(-time.Second))
+	v, err := m.Increment("c", 2)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != 2 || kv.data["c"] != "2" {
+		t.Fatalf("unexpected counter value, got %d, %s", v, kv.data["c"])
+	}
+}
+
+func TestModuleKV_SetIfAbsent_error(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}
+
+	_, err := m.SetIfAbsent("k", "v")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleKV_CompareAndSwap(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		kv := newFakeKV()
+		kv.atomic = atomic
+		kv.data["k"] = "v1"
+		m := kv.module()
+
+		ok, err := m.CompareAndSwap("k", "v0", "v2")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if ok {
+			t.Fatalf("unexpected swap")
+		}
+
+		ok, err = m.CompareAndSwap("k", "v1", "v2")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if !ok {
+			t.Fatalf("expected swap")
+		}
+		if kv.data["k"] != "v2" {
+			t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+		}
+
+		_, err = m.CompareAndSwap("missing", "v1", "v2")
+		if err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		if len(kv.data) != 1 {
+			t.Fatalf("the key lock is not released, got %v", kv.data)
+		}
+	}
+}
+
+func TestModuleKV_atomic_not_supported(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "1"
+
+	var calls []string
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if strings.HasPrefix(path, "kv/cas/") || strings.HasPrefix(path, "kv/increment/") {
+			calls = append(calls, path)
+		}
+		return kv.rf(path, contentType, body)
+	}, ops: &kvServerOps{}}
+	ns := m.Namespace("ns")
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.CompareAndSwap("k", "1", "1"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Increment("k", 1); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		// the namespace shares the cache with the module
+		if _, err := ns.Increment("k", 1); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+
+	if fmt.Sprint(calls) != "[kv/cas/k kv/increment/k]" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+	if kv.data["k"] != "3" || kv.data["ns:k"] != "2" {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+}
+
+func TestModuleKV_CompareAndSwap_error(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path != "kv/cas/k" {
+			t.Fatalf("unexpected path value, got %s", path)
+		}
+		if contentType != "application/json" {
+			t.Fatalf("unexpected contentType value, got %s", contentType)
+		}
+		if string(body) != `{"old":"a","new":"b"}` {
+			t.Fatalf("unexpected body value, got %s", string(body))
+		}
+		return nil, fmt.Errorf("err1")
+	}}
+
+	_, err := m.CompareAndSwap("k", "a", "b")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	m = ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return []byte("bad"), nil
+	}}
+
+	_, err = m.CompareAndSwap("k", "a", "b")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to unmarshal response: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	if _, err := m.CompareAndSwap("", "a", "b"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestModuleKV_Increment(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		kv := newFakeKV()
+		kv.atomic = atomic
+		m := kv.module()
+
+		v, err := m.Increment("k", 5)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if v != 5 {
+			t.Fatalf("unexpected value, got %d", v)
+		}
+
+		v, err = m.Increment("k", -2)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if v != 3 {
+			t.Fatalf("unexpected value, got %d", v)
+		}
+		if kv.data["k"] != "3" {
+			t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+		}
+	}
+}
+
+func TestModuleKV_Increment_error(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "x"
+
+	_, err := kv.module().Increment("k", 1)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != `failed to decode value of the key k: strconv.ParseInt: parsing "x": invalid syntax` {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+	if _, ok := kv.data[kvLockPrefix+"k"]; ok {
+		t.Fatalf("the key lock is not released")
+	}
+
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return []byte("bad"), nil
+	}}
+
+	_, err = m.Increment("k", 1)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to unmarshal response: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleKV_Increment_concurrent(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		kv := newFakeKV()
+		kv.atomic = atomic
+		m := kv.module()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if _, err := m.Increment("counter", 1); err != nil {
+						t.Errorf("unexpected error, got %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if kv.data["counter"] != "100" {
+			t.Fatalf("unexpected counter value, got %s", kv.data["counter"])
+		}
+	}
+}
+
+func TestModuleKV_CompareAndSwap_concurrent(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "0"
+	m := kv.module()
+
+	swaps := make(chan bool, 10)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := m.CompareAndSwap("k", "0", strconv.Itoa(i+1))
+			if err != nil {
+				t.Errorf("unexpected error, got %v", err)
+			}
+			swaps <- ok
+		}(i)
+	}
+	wg.Wait()
+	close(swaps)
+
+	count := 0
+	for ok := range swaps {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("unexpected swaps count, got %d", count)
+	}
+}
+
+func TestModuleKV_withKeyLock_stale(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "a"
+	kv.data[kvLockPrefix+"k"] = strconv.FormatInt(time.Now().Add(-time.Second).UnixNano(), 10)
+
+	ok, err := kv.module().CompareAndSwap("k", "a", "b")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected swap")
+	}
+	if _, ok := kv.data[kvLockPrefix+"k"]; ok {
+		t.Fatalf("the key lock is not released")
+	}
+}
+
+func TestModuleKV_withKeyLock_staleToken(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "a"
+	kv.data[kvLockPrefix+"k"] = "0123:" + strconv.FormatInt(time.Now().Add(-time.Second).UnixNano(), 10)
+
+	ok, err := kv.module().CompareAndSwap("k", "a", "b")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected swap")
+	}
+	if len(kv.data) != 1 {
+		t.Fatalf("the key lock or the takeover marker is not released, got %v", kv.data)
+	}
+}
+
+func TestModuleKV_takeOverKeyLock(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+	lockKey := kvLockPrefix + "k"
+	stale := "0123:" + strconv.FormatInt(time.Now().Add(-time.Second).UnixNano(), 10)
+	kv.data[lockKey] = stale
+
+	taken, err := m.takeOverKeyLock(lockKey, stale, "first")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !taken {
+		t.Fatalf("expected takeover")
+	}
+
+	// the late client has read the same stale value
+	taken, err = m.takeOverKeyLock(lockKey, stale, "second")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if taken {
+		t.Fatalf("unexpected second takeover")
+	}
+	if kv.data[lockKey] != "first" {
+		t.Fatalf("unexpected lock value, got %s", kv.data[lockKey])
+	}
+	if len(kv.data) != 1 {
+		t.Fatalf("the takeover marker is not released, got %v", kv.data)
+	}
+}
+
+func TestModuleKV_takeOverKeyLock_marker(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+	lockKey := kvLockPrefix + "k"
+	stale := "0123:" + strconv.FormatInt(time.Now().Add(-time.Second).UnixNano(), 10)
+	marker := lockKey + ":takeover:0123:0"
+	kv.data[lockKey] = stale
+
+	// the takeover is in progress
+	kv.data[marker] = "x:" + strconv.FormatInt(time.Now().Add(time.Second).UnixNano(), 10)
+	taken, err := m.takeOverKeyLock(lockKey, stale, "lock")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if taken {
+		t.Fatalf("unexpected takeover")
+	}
+
+	// the client crashed during the takeover
+	kv.data[marker] = "x:" + strconv.FormatInt(time.Now().Add(-time.Second).UnixNano(), 10)
+	taken, err = m.takeOverKeyLock(lockKey, stale, "lock")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !taken {
+		t.Fatalf("expected takeover")
+	}
+	if kv.data[lockKey] != "lock" {
+		t.Fatalf("unexpected lock value, got %s", kv.data[lockKey])
+	}
+	if _, ok := kv.data[lockKey+":takeover:0123:1"]; ok {
+		t.Fatalf("the takeover marker is not released")
+	}
+}
+
+func TestModuleKV_releaseKeyLock(t *testing.T) {
+	kv := newFakeKV()
+	lockKey := kvLockPrefix + "k"
+	kv.data[lockKey] = "other"
+
+	kv.module().releaseKeyLock(lockKey, "mine")
+	if kv.data[lockKey] != "other" {
+		t.Fatalf("the lock of another client is released")
+	}
+
+	kv.module().releaseKeyLock(lockKey, "other")
+	if _, ok := kv.data[lockKey]; ok {
+		t.Fatalf("the key lock is not released")
+	}
+}
@@ -0,0 +1,311 @@
+package coreapi
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KVCacheOptions are options for the KVCache.
+type KVCacheOptions struct {
+	// TTL is the time to keep the cached value. Zero means no expiry.
+	TTL time.Duration
+	// MaxEntries is the max number of the cached values. The least recently used values are evicted.
+	// Zero means no limit.
+	MaxEntries int
+	// WatchInterval is the poll interval of the watcher, started with Run. Default is 10 seconds.
+	WatchInterval time.Duration
+	// OnError is called on the watcher errors.
+	OnError func(err error)
+}
+
+// KVCacheStats are the KVCache counters.
+type KVCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type kvCacheEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// KVCache is a local read-through copy of the KV storage with the write-through Put, Upsert and Delete.
+// Run keeps it fresh with the watcher.
+type KVCache struct {
+	kv   ModuleKV
+	opts KVCacheOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru is the list of the entries, the most recently used is in the front
+	lru *list.List
+	// version counts the cache changes. The changes of the keys, fetched by Get, are tracked in changed,
+	// so the fetched value does not overwrite the change or the invalidation, made during the fetch.
+	version uint64
+	fetches map[string]int
+	changed map[string]uint64
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewKVCache creates a new KVCache. opts may be nil.
+func NewKVCache(kv ModuleKV, opts *KVCacheOptions) *KVCache {
+	c := &KVCache{
+		kv:      kv,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		fetches: map[string]int{},
+		changed: map[string]uint64{},
+	}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.WatchInterval <= 0 {
+		c.opts.WatchInterval = defaultKVWatchInterval
+	}
+	return c
+}
+
+// Get returns the value from the cache or, if it is missing or expired, from the storage.
+func (c *KVCache) Get(key string) (string, error) {
+	if v, ok := c.lookup(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return v, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	c.mu.Lock()
+	start := c.version
+	c.fetches[key]++
+	c.mu.Unlock()
+
+	raw, err := c.kv.getRaw(key)
+
+	c.mu.Lock()
+	changed := c.changed[key] > start
+	if c.fetches[key]--; c.fetches[key] == 0 {
+		delete(c.fetches, key)
+		delete(c.changed, key)
+	}
+	if err == nil && !changed {
+		c.setRawLocked(key, raw)
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+	v, expires, ok := decodeTTLValue(raw)
+	if ok && !c.now().Before(expires) {
+		return "", fmt.Errorf("%w: %s", ErrKeyExpired, key)
+	}
+
+	return v, nil
+}
+
+// Put puts the value to the storage and to the cache.
+func (c *KVCache) Put(key, value string) error {
+	if err := c.kv.Put(key, value); err != nil {
+		return err
+	}
+	c.set(key, value)
+	return nil
+}
+
+// Upsert upserts the value in the storage and in the cache.
+func (c *KVCache) Upsert(key, value string) error {
+	if err := c.kv.Upsert(key, value); err != nil {
+		return err
+	}
+	c.set(key, value)
+	return nil
+}
+
+// Delete deletes the value from the storage and from the cache.
+func (c *KVCache) Delete(key string) error {
+	if err := c.kv.Delete(key); err != nil {
+		return err
+	}
+	c.Invalidate(key)
+	return nil
+}
+
+// Invalidate removes the value from the cache.
+func (c *KVCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(key)
+}
+
+// Warmup loads all values from the storage to the cache.
+func (c *KVCache) Warmup() error {
+	all, err := c.kv.allRaw()
+	if err != nil {
+		return err
+	}
+	c.reset(all)
+	return nil
+}
+
+// Stats returns the cache counters.
+func (c *KVCache) Stats() KVCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return KVCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   entries,
+	}
+}
+
+// Run loads all values to the cache and applies the storage changes until the context is done.
+func (c *KVCache) Run(ctx context.Context) {
+	c.kv.watch(ctx, "", c.opts.WatchInterval, true, c.reset, func(e KVEvent) bool {
+		c.apply(e)
+		return true
+	})
+}
+
+func (c *KVCache) apply(e KVEvent) {
+	switch e.Type {
+	case KVEventPut, KVEventUpdate:
+		c.setRaw(e.Key, e.Value)
+	case KVEventDelete:
+		c.Invalidate(e.Key)
+	case KVEventError:
+		if c.opts.OnError != nil {
+			c.opts.OnError(e.Err)
+		}
+	}
+}
+
+func (c *KVCache) lookup(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	entry := e.Value.(*kvCacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return "", false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return entry.value, true
+}
+
+func (c *KVCache) set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(key, value, time.Time{})
+}
+
+// setRaw caches the stored value. The entry expires not later than the stored expiry time, see PutWithTTL.
+// The expired value is removed from the cache.
+func (c *KVCache) setRaw(key, raw string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setRawLocked(key, raw)
+}
+
+func (c *KVCache) setRawLocked(key, raw string) {
+	value, expires, ok := decodeTTLValue(raw)
+	if !ok {
+		c.setLocked(key, raw, time.Time{})
+		return
+	}
+	if !c.now().Before(expires) {
+		c.removeLocked(key)
+		return
+	}
+	c.setLocked(key, value, expires)
+}
+
+// reset replaces the cached values with the stored values
+func (c *KVCache) reset(all map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+
+	c.version++
+	for k := range c.fetches {
+		c.changed[k] = c.version
+	}
+
+	for k, v := range all {
+		c.setRawLocked(k, v)
+	}
+}
+
+// changeLocked counts the change of the key
+func (c *KVCache) changeLocked(key string) {
+	c.version++
+	if c.fetches[key] > 0 {
+		c.changed[key] = c.version
+	}
+}
+
+func (c *KVCache) removeLocked(key string) {
+	c.changeLocked(key)
+
+	if e, ok := c.entries[key]; ok {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// setLocked caches the value with the cache TTL, capped at the stored expiry time, if it is not zero
+func (c *KVCache) setLocked(key, value string, stored time.Time) {
+	c.changeLocked(key)
+
+	var expires time.Time
+	if c.opts.TTL > 0 {
+		expires = c.now().Add(c.opts.TTL)
+	}
+	if !stored.IsZero() && (expires.IsZero() || stored.Before(expires)) {
+		expires = stored
+	}
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*kvCacheEntry)
+		entry.value = value
+		entry.expires = expires
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&kvCacheEntry{key: key, value: value, expires: expires})
+
+	for c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*kvCacheEntry).key)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
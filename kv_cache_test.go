@@ -0,0 +1,331 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingKV(kv *fakeKV, calls *int32) ModuleKV {
+	return ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		atomic.AddInt32(calls, 1)
+		return kv.rf(path, contentType, body)
+	}}
+}
+
+func TestKVCache_Get(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	kv.data["k"] = "v"
+
+	c := NewKVCache(countingKV(kv, &calls), nil)
+
+	for i := 0; i < 3; i++ {
+		v, err := c.Get("k")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if v != "v" {
+			t.Fatalf("unexpected value, got %s", v)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	_, err := c.Get("missing")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Fatalf("unexpected stats, got %+v", stats)
+	}
+}
+
+func TestKVCache_Get_invalidated(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "v"
+
+	var c *KVCache
+	c = NewKVCache(ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		resp, err := kv.rf(path, contentType, body)
+		if path == "kv/get/k" && err == nil {
+			// the key is deleted, while the old value is fetched
+			if errDelete := c.Delete("k"); errDelete != nil {
+				t.Errorf("unexpected error, got %v", errDelete)
+			}
+		}
+		return resp, err
+	}}, nil)
+
+	v, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v" {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Fatalf("unexpected stats, got %+v", stats)
+	}
+
+	_, err = c.Get("k")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if len(c.fetches) != 0 || len(c.changed) != 0 {
+		t.Fatalf("unexpected fetches, got %v, %v", c.fetches, c.changed)
+	}
+}
+
+func TestKVCache_TTL(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	kv.data["k"] = "v"
+
+	now := time.Unix(1000, 0)
+
+	c := NewKVCache(countingKV(kv, &calls), &KVCacheOptions{TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	now = now.Add(time.Second * 59)
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	kv.data["k"] = "v2"
+	now = now.Add(time.Second)
+
+	v, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v2" || calls != 2 {
+		t.Fatalf("unexpected value, got %s, calls %d", v, calls)
+	}
+}
+
+func TestKVCache_stored_expiry(t *testing.T) {
+	var calls int32
+
+	now := time.Unix(1000, 0)
+
+	kv := newFakeKV()
+	kv.data["k"] = encodeTTLValue("v", now.Add(time.Second*10))
+	kv.data["expired"] = encodeTTLValue("v", now.Add(-time.Second))
+
+	c := NewKVCache(countingKV(kv, &calls), &KVCacheOptions{TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	v, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v" {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	// the entry expires with the stored value, before the cache TTL
+	now = now.Add(time.Second * 10)
+	_, err = c.Get("k")
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected ErrKeyExpired, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	_, err = c.Get("expired")
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected ErrKeyExpired, got %v", err)
+	}
+
+	kv.data["k"] = encodeTTLValue("v", now.Add(time.Second*10))
+	if err := c.Warmup(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Fatalf("unexpected stats, got %+v", stats)
+	}
+	if v, ok := c.lookup("k"); !ok || v != "v" {
+		t.Fatalf("unexpected cached value, got %s, %v", v, ok)
+	}
+	now = now.Add(time.Second * 10)
+	if _, ok := c.lookup("k"); ok {
+		t.Fatalf("unexpected cached value after the stored expiry")
+	}
+}
+
+func TestKVCache_MaxEntries(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	kv.data["a"] = "1"
+	kv.data["b"] = "2"
+	kv.data["c"] = "3"
+
+	c := NewKVCache(countingKV(kv, &calls), &KVCacheOptions{MaxEntries: 2})
+
+	for _, k := range []string{"a", "b", "a", "c"} {
+		if _, err := c.Get(k); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+
+	// b is the least recently used, so it is evicted
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats, got %+v", stats)
+	}
+	if _, ok := c.lookup("b"); ok {
+		t.Fatalf("expected evicted entry")
+	}
+	if _, ok := c.lookup("a"); !ok {
+		t.Fatalf("expected cached entry")
+	}
+}
+
+func TestKVCache_write_through(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	c := NewKVCache(countingKV(kv, &calls), nil)
+
+	if err := c.Put("k", "v1"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v, ok := c.lookup("k"); !ok || v != "v1" || kv.data["k"] != "v1" {
+		t.Fatalf("unexpected value, got %s, stored %v", v, kv.data)
+	}
+
+	if err := c.Upsert("k", "v2"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v, ok := c.lookup("k"); !ok || v != "v2" || kv.data["k"] != "v2" {
+		t.Fatalf("unexpected value, got %s, stored %v", v, kv.data)
+	}
+
+	if err := c.Delete("k"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, ok := c.lookup("k"); ok {
+		t.Fatalf("unexpected cached value")
+	}
+	if _, ok := kv.data["k"]; ok {
+		t.Fatalf("unexpected stored value")
+	}
+
+	// the cache is not changed on errors
+	c.set("k", "v3")
+	if err := c.Put("x", ""); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := c.Put("x", "v"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if v, _ := c.lookup("x"); v != "" {
+		t.Fatalf("unexpected cached value, got %s", v)
+	}
+	if err := c.Delete("missing"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err := c.Upsert("", "v"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestKVCache_Warmup(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	kv.data["a"] = "1"
+	kv.data["b"] = "2"
+
+	c := NewKVCache(countingKV(kv, &calls), nil)
+
+	if err := c.Warmup(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, err := c.Get(k); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected calls count, got %d", calls)
+	}
+
+	c = NewKVCache(ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}, nil)
+	if err := c.Warmup(); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestKVCache_Run(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["a"] = "1"
+	kv.data["b"] = "2"
+
+	errs := make(chan error, 1)
+
+	c := NewKVCache(kv.module(), &KVCacheOptions{WatchInterval: time.Millisecond, OnError: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	waitCache := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("cache is not updated, got %+v", c.Stats())
+	}
+
+	waitCache(func() bool { return c.Stats().Entries == 2 })
+
+	kv.mu.Lock()
+	kv.data["a"] = "10"
+	delete(kv.data, "b")
+	kv.mu.Unlock()
+
+	waitCache(func() bool {
+		v, _ := c.lookup("a")
+		return c.Stats().Entries == 1 && v == "10"
+	})
+
+	c.apply(KVEvent{Type: KVEventError, Err: fmt.Errorf("err1")})
+	if err := <-errs; err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	cancel()
+	<-done
+}
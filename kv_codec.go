@@ -0,0 +1,175 @@
+package coreapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Codec encodes values to the KV strings and decodes them back.
+type Codec interface {
+	Marshal(v interface{}) (string, error)
+	Unmarshal(data string, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec struct{}
+
+// Marshal encodes the value as JSON.
+func (JSONCodec) Marshal(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Unmarshal decodes the JSON value.
+func (JSONCodec) Unmarshal(data string, v interface{}) error {
+	return json.Unmarshal([]byte(data), v)
+}
+
+// GobCodec encodes values with encoding/gob. The binary data is stored as base64 string.
+type GobCodec struct{}
+
+// Marshal encodes the value with gob.
+func (GobCodec) Marshal(v interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Unmarshal decodes the gob value.
+func (GobCodec) Unmarshal(data string, v interface{}) error {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// DecodeError is returned, if the stored value can not be decoded.
+type DecodeError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode value of the key %s: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// PutCodec encodes the value with the codec and puts it to the storage.
+func PutCodec[T any](kv ModuleKV, codec Codec, key string, value T) error {
+	s, err := codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value of the key %s: %w", key, err)
+	}
+	return kv.Put(key, s)
+}
+
+// UpsertCodec encodes the value with the codec and upserts it in the storage.
+func UpsertCodec[T any](kv ModuleKV, codec Codec, key string, value T) error {
+	s, err := codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value of the key %s: %w", key, err)
+	}
+	return kv.Upsert(key, s)
+}
+
+// GetCodec gets the value from the storage and decodes it with the codec.
+// The *DecodeError is returned, if the value can not be decoded.
+func GetCodec[T any](kv ModuleKV, codec Codec, key string) (T, error) {
+	var result T
+
+	s, err := kv.Get(key)
+	if err != nil {
+		return result, err
+	}
+
+	if err := codec.Unmarshal(s, &result); err != nil {
+		return result, &DecodeError{Key: key, Value: s, Err: err}
+	}
+
+	return result, nil
+}
+
+// PutJSON encodes the value as JSON and puts it to the storage.
+func PutJSON[T any](kv ModuleKV, key string, value T) error {
+	return PutCodec(kv, JSONCodec{}, key, value)
+}
+
+// UpsertJSON encodes the value as JSON and upserts it in the storage.
+func UpsertJSON[T any](kv ModuleKV, key string, value T) error {
+	return UpsertCodec(kv, JSONCodec{}, key, value)
+}
+
+// GetJSON gets the JSON value from the storage.
+func GetJSON[T any](kv ModuleKV, key string) (T, error) {
+	return GetCodec[T](kv, JSONCodec{}, key)
+}
+
+// GetInt gets the integer value from the storage.
+func (kv ModuleKV) GetInt(key string) (int64, error) {
+	s, err := kv.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	v, errParse := strconv.ParseInt(s, 10, 64)
+	if errParse != nil {
+		return 0, &DecodeError{Key: key, Value: s, Err: errParse}
+	}
+	return v, nil
+}
+
+// GetFloat gets the float value from the storage.
+func (kv ModuleKV) GetFloat(key string) (float64, error) {
+	s, err := kv.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	v, errParse := strconv.ParseFloat(s, 64)
+	if errParse != nil {
+		return 0, &DecodeError{Key: key, Value: s, Err: errParse}
+	}
+	return v, nil
+}
+
+// GetTime gets the time value from the storage. The value should be in the RFC3339 format or an unix timestamp in seconds.
+func (kv ModuleKV) GetTime(key string) (time.Time, error) {
+	s, err := kv.Get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts, errParse := strconv.ParseInt(s, 10, 64); errParse == nil {
+		return time.Unix(ts, 0), nil
+	}
+	v, errParse := time.Parse(time.RFC3339Nano, s)
+	if errParse != nil {
+		return time.Time{}, &DecodeError{Key: key, Value: s, Err: errParse}
+	}
+	return v, nil
+}
+
+// GetDuration gets the duration value from the storage. The value should be in the time.ParseDuration format.
+func (kv ModuleKV) GetDuration(key string) (time.Duration, error) {
+	s, err := kv.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	v, errParse := time.ParseDuration(s)
+	if errParse != nil {
+		return 0, &DecodeError{Key: key, Value: s, Err: errParse}
+	}
+	return v, nil
+}
@@ -0,0 +1,213 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type kvCodecRecord struct {
+	Name  string
+	Count int
+}
+
+func TestPutJSON_GetJSON(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+
+	if err := PutJSON(m, "k", kvCodecRecord{Name: "a", Count: 2}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if kv.data["k"] != `{"Name":"a","Count":2}` {
+		t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+	}
+
+	if err := UpsertJSON(m, "k", kvCodecRecord{Name: "b", Count: 3}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	v, err := GetJSON[kvCodecRecord](m, "k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v.Name != "b" || v.Count != 3 {
+		t.Fatalf("unexpected value, got %+v", v)
+	}
+}
+
+func TestGetJSON_error(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "bad"
+
+	_, err := GetJSON[kvCodecRecord](kv.module(), "k")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %T", err)
+	}
+	if decodeErr.Value != "bad" {
+		t.Fatalf("unexpected error value, got %s", decodeErr.Value)
+	}
+	if err.Error() != "failed to decode value of the key k: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	_, err = GetJSON[kvCodecRecord](kv.module(), "missing")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "variable not exists" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestPutCodec_error(t *testing.T) {
+	err := PutCodec(newFakeKV().module(), JSONCodec{}, "k", func() {})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to encode value of the key k: json: unsupported type: func()" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	err = UpsertCodec(newFakeKV().module(), JSONCodec{}, "k", func() {})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+
+	if err := PutCodec(m, GobCodec{}, "k", kvCodecRecord{Name: "a", Count: 2}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	v, err := GetCodec[kvCodecRecord](m, GobCodec{}, "k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v.Name != "a" || v.Count != 2 {
+		t.Fatalf("unexpected value, got %+v", v)
+	}
+
+	kv.data["k"] = "!!!"
+	_, err = GetCodec[kvCodecRecord](m, GobCodec{}, "k")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to decode value of the key k: illegal base64 data at input byte 0" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	_, err = GobCodec{}.Marshal(func() {})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestModuleKV_GetInt(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["i"] = "-42"
+	kv.data["bad"] = "x"
+
+	v, err := kv.module().GetInt("i")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != -42 {
+		t.Fatalf("unexpected value, got %d", v)
+	}
+
+	_, err = kv.module().GetInt("bad")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+
+	_, err = kv.module().GetInt("missing")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestModuleKV_GetFloat(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["f"] = "1.5"
+	kv.data["bad"] = "x"
+
+	v, err := kv.module().GetFloat("f")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != 1.5 {
+		t.Fatalf("unexpected value, got %f", v)
+	}
+
+	_, err = kv.module().GetFloat("bad")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestModuleKV_GetTime(t *testing.T) {
+	ts := time.Date(2022, 8, 15, 10, 0, 0, 0, time.UTC)
+
+	kv := newFakeKV()
+	kv.data["rfc"] = ts.Format(time.RFC3339Nano)
+	kv.data["unix"] = fmt.Sprintf("%d", ts.Unix())
+	kv.data["bad"] = "x"
+
+	v, err := kv.module().GetTime("rfc")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !v.Equal(ts) {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	v, err = kv.module().GetTime("unix")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !v.Equal(ts) {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	_, err = kv.module().GetTime("bad")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != `failed to decode value of the key bad: parsing time "x" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "x" as "2006"` {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleKV_GetDuration(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["d"] = "1m30s"
+	kv.data["bad"] = "x"
+
+	v, err := kv.module().GetDuration("d")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != time.Second*90 {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	_, err = kv.module().GetDuration("bad")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != `failed to decode value of the key bad: time: invalid duration "x"` {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
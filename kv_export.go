@@ -0,0 +1,141 @@
+package coreapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KVImportMode defines how the imported values are applied to the existing ones.
+type KVImportMode int
+
+// KV import modes
+const (
+	// KVImportMerge puts the new keys and updates the existing keys
+	KVImportMerge KVImportMode = iota
+	// KVImportOverwrite makes the storage equal to the import: the keys missing in the import are deleted
+	KVImportOverwrite
+	// KVImportSkipExisting puts the new keys only
+	KVImportSkipExisting
+)
+
+type kvRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Export writes all values as JSON lines {"key":"...","value":"..."}, sorted by the key.
+// The values are exported as is, so the expiry times are kept. The expired values are skipped.
+// It returns the number of exported values.
+func (kv ModuleKV) Export(w io.Writer) (int, error) {
+	all, err := kv.allRaw()
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(all))
+	now := time.Now()
+	for k, v := range all {
+		if strings.HasPrefix(k, kvLockPrefix) {
+			continue
+		}
+		if _, expires, ok := decodeTTLValue(v); ok && !now.Before(expires) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i, k := range keys {
+		if err := enc.Encode(kvRecord{Key: k, Value: all[k]}); err != nil {
+			return i, fmt.Errorf("failed to write key %s: %w", k, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return len(keys), fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return len(keys), nil
+}
+
+// Import reads the JSON lines, written by Export, and applies them to the storage with the mode.
+// It returns the applied changes. On error, the changes applied before it are returned.
+func (kv ModuleKV) Import(r io.Reader, mode KVImportMode) ([]KVEvent, error) {
+	changes, err := kv.DiffImport(r, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range changes {
+		var errApply error
+		switch c.Type {
+		case KVEventPut:
+			errApply = kv.Put(c.Key, c.Value)
+		case KVEventUpdate:
+			errApply = kv.Upsert(c.Key, c.Value)
+		case KVEventDelete:
+			errApply = kv.Delete(c.Key)
+		}
+		if errApply != nil {
+			return changes[:i], fmt.Errorf("failed to %s key %s: %w", c.Type, c.Key, errApply)
+		}
+	}
+
+	return changes, nil
+}
+
+// DiffImport reads the JSON lines, written by Export, and returns the changes, which Import would apply
+// with the mode, without applying them. The changes are sorted by the key.
+func (kv ModuleKV) DiffImport(r io.Reader, mode KVImportMode) ([]KVEvent, error) {
+	if mode != KVImportMerge && mode != KVImportOverwrite && mode != KVImportSkipExisting {
+		return nil, fmt.Errorf("unknown import mode %d", mode)
+	}
+
+	imported := map[string]string{}
+
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var rec kvRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record %d: %w", line, err)
+		}
+		if err := ValidateName(kv.prefix + rec.Key); err != nil {
+			return nil, fmt.Errorf("bad record %d: %w", line, err)
+		}
+		imported[rec.Key] = rec.Value
+	}
+
+	current, err := kv.allRaw()
+	if err != nil {
+		return nil, err
+	}
+	for k := range current {
+		if strings.HasPrefix(k, kvLockPrefix) {
+			delete(current, k)
+		}
+	}
+
+	var changes []KVEvent
+	for _, c := range diffKV(current, imported) {
+		switch {
+		case c.Type == KVEventUpdate && mode == KVImportSkipExisting:
+			continue
+		case c.Type == KVEventDelete && mode != KVImportOverwrite:
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
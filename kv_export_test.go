@@ -0,0 +1,172 @@
+package coreapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModuleKV_Export(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["b"] = "2"
+	kv.data["a"] = "1\n\"x\""
+	kv.data["expired"] = encodeTTLValue("v", time.Now().Add(-time.Hour))
+	kv.data[kvLockPrefix+"a"] = "1"
+
+	buf := &bytes.Buffer{}
+
+	n, err := kv.module().Export(buf)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected exported count, got %d", n)
+	}
+	if buf.String() != `{"key":"a","value":"1\n\"x\""}`+"\n"+`{"key":"b","value":"2"}`+"\n" {
+		t.Fatalf("unexpected export, got %s", buf.String())
+	}
+}
+
+func TestModuleKV_Export_error(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}
+
+	_, err := m.Export(&bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("err1")
+}
+
+func TestModuleKV_Export_error_write(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["a"] = "1"
+
+	_, err := kv.module().Export(failWriter{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to write export: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+const kvImportData = `{"key":"a","value":"10"}
+{"key":"c","value":"3"}
+`
+
+func newImportFakeKV() *fakeKV {
+	kv := newFakeKV()
+	kv.data["a"] = "1"
+	kv.data["b"] = "2"
+	return kv
+}
+
+func TestModuleKV_Import(t *testing.T) {
+	for mode, expect := range map[KVImportMode]struct {
+		changes string
+		data    string
+	}{
+		KVImportMerge: {
+			changes: "[{update a 10 1 <nil>} {put c 3  <nil>}]",
+			data:    "map[a:10 b:2 c:3]",
+		},
+		KVImportOverwrite: {
+			changes: "[{update a 10 1 <nil>} {delete b  2 <nil>} {put c 3  <nil>}]",
+			data:    "map[a:10 c:3]",
+		},
+		KVImportSkipExisting: {
+			changes: "[{put c 3  <nil>}]",
+			data:    "map[a:1 b:2 c:3]",
+		},
+	} {
+		kv := newImportFakeKV()
+
+		diff, err := kv.module().DiffImport(strings.NewReader(kvImportData), mode)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if fmt.Sprint(diff) != expect.changes {
+			t.Fatalf("unexpected diff for mode %d, got %v", mode, diff)
+		}
+		if fmt.Sprint(kv.data) != "map[a:1 b:2]" {
+			t.Fatalf("the storage is changed by the dry run, got %v", kv.data)
+		}
+
+		changes, err := kv.module().Import(strings.NewReader(kvImportData), mode)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if fmt.Sprint(changes) != expect.changes {
+			t.Fatalf("unexpected changes for mode %d, got %v", mode, changes)
+		}
+		if fmt.Sprint(kv.data) != expect.data {
+			t.Fatalf("unexpected storage for mode %d, got %v", mode, kv.data)
+		}
+	}
+}
+
+func TestModuleKV_Export_Import_roundtrip(t *testing.T) {
+	src := newFakeKV()
+	src.data["team-a:k"] = "v"
+	src.data["ttl"] = encodeTTLValue("v", time.Now().Add(time.Hour))
+
+	buf := &bytes.Buffer{}
+	if _, err := src.module().Export(buf); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	dst := newFakeKV()
+	if _, err := dst.module().Import(buf, KVImportOverwrite); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if fmt.Sprint(dst.data) != fmt.Sprint(src.data) {
+		t.Fatalf("unexpected storage, got %v", dst.data)
+	}
+}
+
+func TestModuleKV_Import_error(t *testing.T) {
+	kv := newImportFakeKV()
+
+	_, err := kv.module().Import(strings.NewReader(kvImportData), KVImportMode(10))
+	if err == nil || err.Error() != "unknown import mode 10" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, err = kv.module().Import(strings.NewReader(`{"key":"a","value":"1"}`+"\nbad"), KVImportMerge)
+	if err == nil || err.Error() != "failed to read record 2: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, err = kv.module().Import(strings.NewReader(`{"key":"","value":"1"}`), KVImportMerge)
+	if !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path == "kv/all" {
+			return []byte(`{"a":"1"}`), nil
+		}
+		return nil, fmt.Errorf("err1")
+	}}
+
+	changes, err := m.Import(strings.NewReader(kvImportData), KVImportMerge)
+	if err == nil || err.Error() != "failed to update key a: err1" {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unexpected applied changes, got %v", changes)
+	}
+}
@@ -1,10 +1,111 @@
 package coreapi
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
+// fakeKV emulates the balerter kv module in memory.
+type fakeKV struct {
+	mu   sync.Mutex
+	data map[string]string
+	// atomic enables the server-side atomic operations
+	atomic bool
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{data: map[string]string{}}
+}
+
+func (f *fakeKV) module() ModuleKV {
+	return ModuleKV{rf: f.rf, ops: &kvServerOps{}}
+}
+
+func (f *fakeKV) rf(path, _ string, body []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path == "kv/all" {
+		return json.Marshal(f.data)
+	}
+
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) != 3 || parts[0] != "kv" {
+		return nil, fmt.Errorf("unexpected path %s", path)
+	}
+	key, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	switch parts[1] {
+	case "put":
+		if _, ok := f.data[key]; ok {
+			return nil, fmt.Errorf("variable already exists")
+		}
+		f.data[key] = string(body)
+	case "upsert":
+		f.data[key] = string(body)
+	case "delete":
+		if _, ok := f.data[key]; !ok {
+			return nil, fmt.Errorf("variable not exists")
+		}
+		delete(f.data, key)
+	case "get":
+		v, ok := f.data[key]
+		if !ok {
+			return nil, fmt.Errorf("variable not exists")
+		}
+		return json.Marshal(v)
+	case "cas":
+		if !f.atomic {
+			return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+		}
+		req := struct {
+			Old string `json:"old"`
+			New string `json:"new"`
+		}{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		v, ok := f.data[key]
+		if !ok {
+			return nil, fmt.Errorf("variable not exists")
+		}
+		if v != req.Old {
+			return json.Marshal(false)
+		}
+		f.data[key] = req.New
+		return json.Marshal(true)
+	case "increment":
+		if !f.atomic {
+			return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+		}
+		delta, err := strconv.ParseInt(string(body), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		var v int64
+		if current, ok := f.data[key]; ok {
+			if v, err = strconv.ParseInt(current, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		f.data[key] = strconv.FormatInt(v+delta, 10)
+		return json.Marshal(v + delta)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+	}
+
+	return nil, nil
+}
+
 func TestModuleKV_Put_error(t *testing.T) {
 	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
 		if path != "kv/put/k" {
@@ -277,3 +378,94 @@ func TestModuleKV_All(t *testing.T) {
 		t.Fatalf("unexpected response value, got %s", resp)
 	}
 }
+
+func TestModuleKV_Namespace(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["other"] = "v0"
+
+	root := kv.module()
+	a := root.Namespace("team-a")
+	ab := a.Namespace("b")
+
+	if ab.Prefix() != "team-a:b:" {
+		t.Fatalf("unexpected prefix value, got %s", ab.Prefix())
+	}
+
+	if err := a.Put("k", "v1"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := ab.Upsert("k", "v2"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if kv.data["team-a:k"] != "v1" || kv.data["team-a:b:k"] != "v2" {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+
+	v, err := ab.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v2" {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	all, err := a.All()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(all) != 2 || all["k"] != "v1" || all["b:k"] != "v2" {
+		t.Fatalf("unexpected all value, got %v", all)
+	}
+
+	all, err = ab.All()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(all) != 1 || all["k"] != "v2" {
+		t.Fatalf("unexpected all value, got %v", all)
+	}
+
+	if err := a.Delete("k"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, ok := kv.data["team-a:k"]; ok {
+		t.Fatalf("expected deleted key, got %v", kv.data)
+	}
+
+	all, err = root.All()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("unexpected all value, got %v", all)
+	}
+}
+
+func TestModuleKV_Namespace_invalid(t *testing.T) {
+	kv := newFakeKV()
+
+	for name, expect := range map[string]string{
+		"":    "bad namespace: invalid name: name is empty",
+		"x:y": `bad namespace: invalid name "x:y": name contains the separator ":"`,
+	} {
+		// the error is kept in the nested namespaces
+		m := kv.module().Namespace(name).Namespace("n")
+
+		err := m.Put("k", "v")
+		if err == nil || err.Error() != expect {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+		if !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected ErrInvalidName, got %v", err)
+		}
+		if _, err := m.All(); err == nil || err.Error() != expect {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+		if _, err := m.Increment("k", 1); err == nil || err.Error() != expect {
+			t.Fatalf("unexpected error value, got %v", err)
+		}
+	}
+	if len(kv.data) != 0 {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+}
@@ -0,0 +1,152 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultKVSweepInterval = time.Minute
+
+// kvTTLMarker starts the stored value with the expiry time: marker, unix nanoseconds, ':' and the value.
+// The plain values in the form "__ttl:<digits>:..." are reserved, they are read as the values with the expiry time.
+const kvTTLMarker = "__ttl:"
+
+// ErrKeyExpired is returned by Get for the expired key. The expired keys are treated as missing,
+// so ErrKeyExpired matches ErrKeyNotFound with errors.Is.
+var ErrKeyExpired error = keyExpiredError{}
+
+type keyExpiredError struct{}
+
+func (keyExpiredError) Error() string {
+	return "key expired"
+}
+
+// Is reports the expired key as the missing one
+func (keyExpiredError) Is(target error) bool {
+	return target == ErrKeyNotFound
+}
+
+func encodeTTLValue(value string, expires time.Time) string {
+	return kvTTLMarker + strconv.FormatInt(expires.UnixNano(), 10) + ":" + value
+}
+
+// decodeTTLValue returns the value and the expiry time. ok is false, if the value has no expiry time.
+func decodeTTLValue(raw string) (value string, expires time.Time, ok bool) {
+	if !strings.HasPrefix(raw, kvTTLMarker) {
+		return raw, time.Time{}, false
+	}
+	rest := raw[len(kvTTLMarker):]
+	i := strings.IndexByte(rest, ':')
+	if i < 0 {
+		return raw, time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(rest[:i], 10, 64)
+	if err != nil {
+		return raw, time.Time{}, false
+	}
+	return rest[i+1:], time.Unix(0, ts), true
+}
+
+// PutWithTTL puts the value to the storage with the expiry time. The expired key is replaced.
+func (kv ModuleKV) PutWithTTL(key, value string, ttl time.Duration) error {
+	v := encodeTTLValue(value, time.Now().Add(ttl))
+
+	errPut := kv.Put(key, v)
+	if errPut == nil {
+		return nil
+	}
+
+	current, errGet := kv.getRaw(key)
+	if errGet != nil {
+		return errPut
+	}
+	replaced, err := kv.replaceExpired(key, current, v)
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		return errPut
+	}
+
+	return nil
+}
+
+// replaceExpired replaces the current expired value of the key with CompareAndSwap.
+// It returns false, if the current value is not expired or is changed concurrently.
+func (kv ModuleKV) replaceExpired(key, current, value string) (bool, error) {
+	if _, expires, ok := decodeTTLValue(current); !ok || time.Now().Before(expires) {
+		return false, nil
+	}
+	return kv.compareAndSwapRaw(key, current, value)
+}
+
+// UpsertWithTTL upserts the value in the storage with the expiry time.
+func (kv ModuleKV) UpsertWithTTL(key, value string, ttl time.Duration) error {
+	return kv.Upsert(key, encodeTTLValue(value, time.Now().Add(ttl)))
+}
+
+// TTL returns the time left before the key expiry. It returns false, if the key has no expiry time.
+func (kv ModuleKV) TTL(key string) (time.Duration, bool, error) {
+	raw, err := kv.getRaw(key)
+	if err != nil {
+		return 0, false, err
+	}
+	_, expires, ok := decodeTTLValue(raw)
+	if !ok {
+		return 0, false, nil
+	}
+	return time.Until(expires), true, nil
+}
+
+// DeleteExpired deletes the expired keys and returns the number of deleted keys.
+func (kv ModuleKV) DeleteExpired() (int, error) {
+	all, err := kv.allRaw()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	now := time.Now()
+
+	for k, v := range all {
+		if _, expires, ok := decodeTTLValue(v); !ok || now.Before(expires) {
+			continue
+		}
+		// the key could be updated after All, so check it again
+		current, errGet := kv.getRaw(k)
+		if errGet != nil || current != v {
+			continue
+		}
+		if err := kv.Delete(k); err != nil {
+			return deleted, fmt.Errorf("failed to delete expired key %s: %w", k, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunSweeper deletes the expired keys with the interval until the context is done. The interval <= 0 means 1 minute.
+// Errors are passed to the onError callback, if it is not nil.
+func (kv ModuleKV) RunSweeper(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultKVSweepInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := kv.DeleteExpired(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDecodeTTLValue(t *testing.T) {
+	expires := time.Unix(100, 5)
+
+	value, e, ok := decodeTTLValue(encodeTTLValue("a:b", expires))
+	if !ok {
+		t.Fatalf("expected ttl value")
+	}
+	if value != "a:b" || !e.Equal(expires) {
+		t.Fatalf("unexpected value, got %s, %s", value, e)
+	}
+
+	for _, raw := range []string{"plain", kvTTLMarker + "x", kvTTLMarker + "x:v"} {
+		value, _, ok := decodeTTLValue(raw)
+		if ok {
+			t.Fatalf("unexpected ttl value for %q", raw)
+		}
+		if value != raw {
+			t.Fatalf("unexpected value, got %s", value)
+		}
+	}
+}
+
+func TestModuleKV_PutWithTTL(t *testing.T) {
+	kv := newFakeKV()
+	m := kv.module()
+
+	if err := m.PutWithTTL("k", "v", time.Hour); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	v, err := m.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v" {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+
+	ttl, ok, err := m.TTL("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok || ttl <= time.Minute*59 || ttl > time.Hour {
+		t.Fatalf("unexpected ttl value, got %s, %v", ttl, ok)
+	}
+
+	// the key is not expired yet
+	if err := m.PutWithTTL("k", "v2", time.Hour); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if err := m.UpsertWithTTL("k", "v3", -time.Second); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, err = m.Get("k")
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected ErrKeyExpired, got %v", err)
+	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if err.Error() != "key expired: k" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	// the expired key is replaced
+	if err := m.PutWithTTL("k", "v4", time.Hour); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	v, err = m.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v != "v4" {
+		t.Fatalf("unexpected value, got %s", v)
+	}
+}
+
+func TestModuleKV_CompareAndSwap_ttl(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		kv := newFakeKV()
+		kv.atomic = atomic
+		m := kv.module()
+
+		if err := m.PutWithTTL("k", "v1", time.Hour); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		_, expires, _ := decodeTTLValue(kv.data["k"])
+
+		v, err := m.Get("k")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		swapped, err := m.CompareAndSwap("k", v, "v2")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if !swapped {
+			t.Fatalf("expected swapped value, atomic %v", atomic)
+		}
+		if kv.data["k"] != encodeTTLValue("v2", expires) {
+			t.Fatalf("unexpected stored value, got %s", kv.data["k"])
+		}
+
+		swapped, err = m.CompareAndSwap("k", "v1", "v3")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if swapped {
+			t.Fatalf("unexpected swapped value")
+		}
+
+		// the expired value is not swapped
+		if err := m.UpsertWithTTL("k", "v4", -time.Second); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		swapped, err = m.CompareAndSwap("k", "v4", "v5")
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if swapped {
+			t.Fatalf("unexpected swapped expired value")
+		}
+	}
+}
+
+func TestModuleKV_Increment_ttl(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		kv := newFakeKV()
+		kv.atomic = atomic
+		m := kv.module()
+
+		if err := m.PutWithTTL("k", "5", time.Hour); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		_, expires, _ := decodeTTLValue(kv.data["k"])
+
+		v, err := m.Increment("k", 2)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if v != 7 {
+			t.Fatalf("unexpected value, got %d", v)
+		}
+		if kv.data["k"] != encodeTTLValue("7", expires) {
+			t.Fatalf("unexpected stored value, got %s, atomic %v", kv.data["k"], atomic)
+		}
+
+		// the expired value is replaced with the delta
+		if err := m.UpsertWithTTL("k", "5", -time.Second); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		v, err = m.Increment("k", 2)
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if v != 2 || kv.data["k"] != "2" {
+			t.Fatalf("unexpected value, got %d, %s", v, kv.data["k"])
+		}
+	}
+}
+
+func TestModuleKV_PutWithTTL_error(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}
+
+	err := m.PutWithTTL("k", "v", time.Hour)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleKV_TTL_without_expiry(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["k"] = "v"
+
+	_, ok, err := kv.module().TTL("k")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected ttl for the plain value")
+	}
+
+	_, _, err = kv.module().TTL("missing")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestModuleKV_All_expired(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["plain"] = "v1"
+	kv.data["ttl"] = encodeTTLValue("v2", time.Now().Add(time.Hour))
+	kv.data["expired"] = encodeTTLValue("v3", time.Now().Add(-time.Hour))
+
+	all, err := kv.module().All()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(all) != 2 || all["plain"] != "v1" || all["ttl"] != "v2" {
+		t.Fatalf("unexpected all value, got %v", all)
+	}
+}
+
+func TestModuleKV_DeleteExpired(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["plain"] = "v1"
+	kv.data["ns:ttl"] = encodeTTLValue("v2", time.Now().Add(time.Hour))
+	kv.data["ns:expired"] = encodeTTLValue("v3", time.Now().Add(-time.Hour))
+	kv.data["expired"] = encodeTTLValue("v4", time.Now().Add(-time.Hour))
+
+	n, err := kv.module().Namespace("ns").DeleteExpired()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected deleted count, got %d", n)
+	}
+	if len(kv.data) != 3 {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		kv.module().RunSweeper(ctx, time.Millisecond, nil)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		kv.mu.Lock()
+		l := len(kv.data)
+		kv.mu.Unlock()
+		if l == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(kv.data) != 2 {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+
+	// the default interval is used
+	kv.module().RunSweeper(ctx, 0, nil)
+}
+
+func TestModuleKV_DeleteExpired_error(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}
+
+	_, err := m.DeleteExpired()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
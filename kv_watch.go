@@ -0,0 +1,148 @@
+package coreapi
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	kvWatchMaxBackoffFactor = 32
+	defaultKVWatchInterval  = time.Second * 10
+)
+
+// KVEventType is the type of the KV change event.
+type KVEventType string
+
+// KV change event types
+const (
+	KVEventPut    KVEventType = "put"
+	KVEventUpdate KVEventType = "update"
+	KVEventDelete KVEventType = "delete"
+	KVEventError  KVEventType = "error"
+)
+
+// KVEvent is the KV change event.
+type KVEvent struct {
+	Type     KVEventType
+	Key      string
+	Value    string
+	OldValue string
+	// Err is the error of the KVEventError event
+	Err error
+}
+
+// Watch polls the storage with the interval and sends the changes of the keys with the prefix to the channel.
+// The first snapshot is the baseline, so the changes are reported after it. The changes are computed by diffing
+// the All snapshots, so the changes between two polls are merged. On errors, the KVEventError is sent and
+// the poll interval is doubled up to 32 intervals. The interval <= 0 means 10 seconds.
+// The channel is closed, when the context is done.
+func (kv ModuleKV) Watch(ctx context.Context, prefix string, interval time.Duration) <-chan KVEvent {
+	ch := make(chan KVEvent)
+
+	go func() {
+		defer close(ch)
+		kv.watch(ctx, prefix, interval, false, nil, func(e KVEvent) bool {
+			select {
+			case ch <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return ch
+}
+
+// watch polls the storage until the context is done or emit returns false. init is called with the first snapshot.
+// The stored values are reported as is, with the expiry time, if raw is true, see PutWithTTL.
+func (kv ModuleKV) watch(ctx context.Context, prefix string, interval time.Duration, raw bool, init func(map[string]string), emit func(KVEvent) bool) {
+	if interval <= 0 {
+		interval = defaultKVWatchInterval
+	}
+
+	all := kv.All
+	if raw {
+		all = kv.allRaw
+	}
+
+	var prev map[string]string
+	wait := time.Duration(0)
+
+	for {
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		snapshot, err := all()
+		if err != nil {
+			if !emit(KVEvent{Type: KVEventError, Err: err}) {
+				return
+			}
+			if wait < interval {
+				wait = interval
+			}
+			if wait *= 2; wait > interval*kvWatchMaxBackoffFactor {
+				wait = interval * kvWatchMaxBackoffFactor
+			}
+			continue
+		}
+		wait = interval
+
+		cur := make(map[string]string, len(snapshot))
+		for k, v := range snapshot {
+			if strings.HasPrefix(k, prefix) {
+				cur[k] = v
+			}
+		}
+
+		if prev == nil {
+			prev = cur
+			if init != nil {
+				init(cur)
+			}
+			continue
+		}
+
+		for _, e := range diffKV(prev, cur) {
+			if !emit(e) {
+				return
+			}
+		}
+		prev = cur
+	}
+}
+
+// diffKV returns the events for the changes between the snapshots, sorted by the key
+func diffKV(prev, cur map[string]string) []KVEvent {
+	var events []KVEvent
+
+	for k, v := range cur {
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			events = append(events, KVEvent{Type: KVEventPut, Key: k, Value: v})
+		case old != v:
+			events = append(events, KVEvent{Type: KVEventUpdate, Key: k, Value: v, OldValue: old})
+		}
+	}
+	for k, v := range prev {
+		if _, ok := cur[k]; !ok {
+			events = append(events, KVEvent{Type: KVEventDelete, Key: k, OldValue: v})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+
+	return events
+}
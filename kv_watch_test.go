@@ -0,0 +1,149 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiffKV(t *testing.T) {
+	events := diffKV(
+		map[string]string{"a": "1", "b": "2", "c": "3"},
+		map[string]string{"a": "1", "b": "20", "d": "4"},
+	)
+
+	expect := []KVEvent{
+		{Type: KVEventUpdate, Key: "b", Value: "20", OldValue: "2"},
+		{Type: KVEventDelete, Key: "c", OldValue: "3"},
+		{Type: KVEventPut, Key: "d", Value: "4"},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected events, got %v", events)
+	}
+}
+
+func waitKVEvent(t *testing.T, ch <-chan KVEvent) KVEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("event is not received")
+	}
+	return KVEvent{}
+}
+
+func TestModuleKV_Watch(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["toggle:a"] = "on"
+	kv.data["other"] = "x"
+
+	// the baseline snapshot is taken with the first kv/all call
+	baseline := make(chan struct{})
+	var once sync.Once
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		resp, err := kv.rf(path, contentType, body)
+		if path == "kv/all" {
+			once.Do(func() { close(baseline) })
+		}
+		return resp, err
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := m.Watch(ctx, "toggle:", time.Millisecond)
+
+	select {
+	case <-baseline:
+	case <-time.After(time.Second):
+		t.Fatalf("baseline snapshot is not taken")
+	}
+
+	kv.mu.Lock()
+	kv.data["toggle:a"] = "off"
+	kv.mu.Unlock()
+
+	e := waitKVEvent(t, ch)
+	if e.Type != KVEventUpdate || e.Key != "toggle:a" || e.Value != "off" || e.OldValue != "on" {
+		t.Fatalf("unexpected event, got %+v", e)
+	}
+
+	kv.mu.Lock()
+	kv.data["toggle:b"] = "on"
+	kv.data["other"] = "y"
+	kv.mu.Unlock()
+
+	e = waitKVEvent(t, ch)
+	if e.Type != KVEventPut || e.Key != "toggle:b" || e.Value != "on" {
+		t.Fatalf("unexpected event, got %+v", e)
+	}
+
+	kv.mu.Lock()
+	delete(kv.data, "toggle:a")
+	kv.mu.Unlock()
+
+	e = waitKVEvent(t, ch)
+	if e.Type != KVEventDelete || e.Key != "toggle:a" || e.OldValue != "off" {
+		t.Fatalf("unexpected event, got %+v", e)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestModuleKV_Watch_error(t *testing.T) {
+	var calls int32
+
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, fmt.Errorf("err1")
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := m.Watch(ctx, "", time.Millisecond)
+
+	e := waitKVEvent(t, ch)
+	if e.Type != KVEventError || e.Err == nil || e.Err.Error() != "err1" {
+		t.Fatalf("unexpected event, got %+v", e)
+	}
+
+	// the backoff grows with errors: 2, 4, 8, 16, 32, 32... intervals
+	time.Sleep(time.Millisecond * 50)
+	if n := atomic.LoadInt32(&calls); n > 6 {
+		t.Fatalf("unexpected calls count with backoff, got %d", n)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestModuleKV_Watch_interval(t *testing.T) {
+	var calls int32
+
+	kv := newFakeKV()
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		return kv.rf(path, contentType, body)
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := m.Watch(ctx, "", 0)
+
+	// the zero interval is defaulted, so the storage is not polled in the loop
+	time.Sleep(time.Millisecond * 20)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("unexpected calls count, got %d", n)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,363 @@
+package coreapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLockTTL           = time.Second * 30
+	defaultLockRetryInterval = time.Second
+)
+
+// ErrLockNotHeld is returned on Unlock, if the lock is not held by the locker.
+var ErrLockNotHeld = errors.New("lock is not held")
+
+// LockOptions are options for the Locker.
+type LockOptions struct {
+	// Owner is the lock owner ID. Default is a random ID.
+	Owner string
+	// TTL is the lock lease time. The lock of the gone owner is taken over after the TTL. Default is 30 seconds.
+	TTL time.Duration
+	// RenewInterval is the lease renewal interval. Default is TTL/3.
+	RenewInterval time.Duration
+	// RetryInterval is the interval of the acquire attempts in Lock. Default is 1 second.
+	RetryInterval time.Duration
+}
+
+type lockValue struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// Locker is a distributed lock, stored in the KV module.
+// The lock value contains the owner ID and the lease expiry time. While the lock is held, the lease is renewed
+// in the background. The lock with the expired lease is taken over by other owners.
+type Locker struct {
+	kv            ModuleKV
+	key           string
+	owner         string
+	ttl           time.Duration
+	renewInterval time.Duration
+	retryInterval time.Duration
+
+	mu    sync.Mutex
+	value string
+	stop  chan struct{}
+	done  chan struct{}
+	lost  chan struct{}
+}
+
+// NewLocker creates a new Locker for the lock name. opts may be nil.
+func NewLocker(kv ModuleKV, name string, opts *LockOptions) *Locker {
+	l := &Locker{
+		kv:            kv,
+		key:           name,
+		ttl:           defaultLockTTL,
+		retryInterval: defaultLockRetryInterval,
+	}
+	if opts != nil {
+		l.owner = opts.Owner
+		if opts.TTL > 0 {
+			l.ttl = opts.TTL
+		}
+		l.renewInterval = opts.RenewInterval
+		if opts.RetryInterval > 0 {
+			l.retryInterval = opts.RetryInterval
+		}
+	}
+	if l.owner == "" {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		l.owner = hex.EncodeToString(b)
+	}
+	if l.renewInterval <= 0 || l.renewInterval >= l.ttl {
+		l.renewInterval = l.ttl / 3
+	}
+	return l
+}
+
+// Owner returns the owner ID of the locker.
+func (l *Locker) Owner() string {
+	return l.owner
+}
+
+// Held returns true, if the lock is held by the locker.
+func (l *Locker) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.value != ""
+}
+
+// Lost returns the channel, which is closed when the acquired lock is lost, e.g. the lease renewal fails
+// until one renewal interval before the lease expiry.
+// It returns nil, if the lock is not acquired.
+func (l *Locker) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// TryLock tries to acquire the lock once. It returns false, if the lock is held by other owner.
+func (l *Locker) TryLock() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.value != "" {
+		return true, nil
+	}
+
+	value, err := l.newValue()
+	if err != nil {
+		return false, err
+	}
+
+	acquired, err := l.kv.SetIfAbsent(l.key, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", l.key, err)
+	}
+
+	if !acquired {
+		current, errGet := l.kv.Get(l.key)
+		if errGet != nil {
+			return false, fmt.Errorf("failed to get lock %s: %w", l.key, errGet)
+		}
+
+		var v lockValue
+		errUnmarshal := json.Unmarshal([]byte(current), &v)
+		// the broken lock value is taken over as the expired one
+		if errUnmarshal == nil && v.Owner != l.owner && time.Now().Before(v.Expires) {
+			return false, nil
+		}
+
+		acquired, err = l.kv.CompareAndSwap(l.key, current, value)
+		if err != nil {
+			return false, fmt.Errorf("failed to take over lock %s: %w", l.key, err)
+		}
+		if !acquired {
+			return false, nil
+		}
+	}
+
+	l.value = value
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	l.lost = make(chan struct{})
+
+	go l.renew(l.stop, l.done, l.lost)
+
+	return true, nil
+}
+
+// Lock acquires the lock, retrying until the context is done.
+func (l *Locker) Lock(ctx context.Context) error {
+	t := time.NewTicker(l.retryInterval)
+	defer t.Stop()
+
+	for {
+		acquired, err := l.TryLock()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Unlock stops the lease renewal and releases the lock.
+// ErrLockNotHeld is returned, if the lock is not held or is lost.
+func (l *Locker) Unlock() error {
+	l.mu.Lock()
+	stop, done := l.stop, l.done
+	l.mu.Unlock()
+
+	if stop == nil {
+		return ErrLockNotHeld
+	}
+
+	close(stop)
+	<-done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	value := l.value
+	l.value = ""
+	l.stop = nil
+	l.done = nil
+	l.lost = nil
+
+	if value == "" {
+		return ErrLockNotHeld
+	}
+
+	current, err := l.kv.Get(l.key)
+	if err != nil {
+		return fmt.Errorf("failed to get lock %s: %w", l.key, err)
+	}
+	if current != value {
+		return ErrLockNotHeld
+	}
+
+	if err := l.kv.Delete(l.key); err != nil {
+		return fmt.Errorf("failed to delete lock %s: %w", l.key, err)
+	}
+
+	return nil
+}
+
+// renew renews the lease until the stop channel is closed or the lock is lost
+func (l *Locker) renew(stop <-chan struct{}, done, lost chan struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(l.renewInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		l.mu.Lock()
+		current := l.value
+		l.mu.Unlock()
+
+		var expires time.Time
+		var v lockValue
+		if err := json.Unmarshal([]byte(current), &v); err == nil {
+			expires = v.Expires
+		}
+
+		value, err := l.newValue()
+		if err == nil {
+			var swapped bool
+			swapped, err = l.kv.CompareAndSwap(l.key, current, value)
+			if err == nil && swapped {
+				l.mu.Lock()
+				l.value = value
+				l.mu.Unlock()
+				continue
+			}
+			if err == nil {
+				// the lock is taken over by other owner
+				expires = time.Time{}
+			}
+		}
+
+		// keep trying on errors, while the lease has the margin of one renewal interval,
+		// so the loss is reported before other owners can take the lock over
+		if time.Now().Before(expires.Add(-l.renewInterval)) {
+			continue
+		}
+
+		l.mu.Lock()
+		l.value = ""
+		l.mu.Unlock()
+		close(lost)
+
+		return
+	}
+}
+
+func (l *Locker) newValue() (string, error) {
+	b, err := json.Marshal(lockValue{Owner: l.owner, Expires: time.Now().Add(l.ttl)})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal lock value: %w", err)
+	}
+	return string(b), nil
+}
+
+// LeaderElectorOptions are options for the LeaderElector.
+type LeaderElectorOptions struct {
+	// Lock are options for the leader lock
+	Lock *LockOptions
+	// OnStartedLeading is called in the new goroutine, when the leadership is gained.
+	// The context is canceled, when the leadership is lost.
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called, when the leadership is lost.
+	OnStoppedLeading func()
+	// OnError is called on the lock errors.
+	OnError func(err error)
+}
+
+// LeaderElector elects the leader among the processes with the Locker.
+type LeaderElector struct {
+	locker *Locker
+	opts   LeaderElectorOptions
+}
+
+// NewLeaderElector creates a new LeaderElector for the election name.
+func NewLeaderElector(kv ModuleKV, name string, opts LeaderElectorOptions) *LeaderElector {
+	return &LeaderElector{
+		locker: NewLocker(kv, name, opts.Lock),
+		opts:   opts,
+	}
+}
+
+// IsLeader returns true, if the process is the leader.
+func (e *LeaderElector) IsLeader() bool {
+	return e.locker.Held()
+}
+
+// Run takes part in the election until the context is done. The leadership is released on exit.
+func (e *LeaderElector) Run(ctx context.Context) {
+	t := time.NewTicker(e.locker.retryInterval)
+	defer t.Stop()
+
+	for ctx.Err() == nil {
+		acquired, err := e.locker.TryLock()
+		if err != nil && e.opts.OnError != nil {
+			e.opts.OnError(err)
+		}
+
+		if acquired {
+			e.lead(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+	}
+}
+
+// lead runs the leader callbacks until the leadership is lost or the context is done
+func (e *LeaderElector) lead(ctx context.Context) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+
+	if e.opts.OnStartedLeading != nil {
+		go e.opts.OnStartedLeading(leaderCtx)
+	}
+
+	select {
+	case <-ctx.Done():
+		// the leader work is stopped before the lock is released
+		cancel()
+		if err := e.locker.Unlock(); err != nil && e.opts.OnError != nil {
+			e.opts.OnError(err)
+		}
+	case <-e.locker.Lost():
+		cancel()
+		// Unlock resets the lost lock state
+		_ = e.locker.Unlock()
+	}
+
+	if e.opts.OnStoppedLeading != nil {
+		e.opts.OnStoppedLeading()
+	}
+}
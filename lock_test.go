@@ -0,0 +1,309 @@
+package coreapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocker_TryLock_Unlock(t *testing.T) {
+	kv := newFakeKV()
+
+	l1 := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o1"})
+	l2 := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o2"})
+
+	ok, err := l1.TryLock()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok || !l1.Held() {
+		t.Fatalf("expected acquired lock")
+	}
+
+	v := lockValue{}
+	if err := json.Unmarshal([]byte(kv.data["lock"]), &v); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if v.Owner != "o1" || v.Expires.Before(time.Now()) {
+		t.Fatalf("unexpected lock value, got %+v", v)
+	}
+
+	ok, err = l2.TryLock()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if ok || l2.Held() {
+		t.Fatalf("unexpected acquired lock")
+	}
+
+	if err := l1.Unlock(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if l1.Held() {
+		t.Fatalf("unexpected held lock")
+	}
+	if _, ok := kv.data["lock"]; ok {
+		t.Fatalf("expected deleted lock")
+	}
+	if err := l1.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected ErrLockNotHeld, got %v", err)
+	}
+
+	ok, err = l2.TryLock()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected acquired lock")
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestLocker_TryLock_stale(t *testing.T) {
+	kv := newFakeKV()
+	b, _ := json.Marshal(lockValue{Owner: "gone", Expires: time.Now().Add(-time.Second)})
+	kv.data["lock"] = string(b)
+
+	l := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o1"})
+
+	ok, err := l.TryLock()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected taken over lock")
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestLocker_TryLock_error(t *testing.T) {
+	l := NewLocker(ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}, "lock", nil)
+
+	_, err := l.TryLock()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to acquire lock lock: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+	if len(l.Owner()) != 16 {
+		t.Fatalf("unexpected owner value, got %s", l.Owner())
+	}
+}
+
+func TestLocker_renew(t *testing.T) {
+	kv := newFakeKV()
+
+	l := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o1", TTL: time.Millisecond * 100, RenewInterval: time.Millisecond * 10})
+
+	ok, err := l.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("expected acquired lock, got %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	if !l.Held() {
+		t.Fatalf("expected held lock after the ttl")
+	}
+
+	// other owner takes the lock over
+	kv.mu.Lock()
+	b, _ := json.Marshal(lockValue{Owner: "o2", Expires: time.Now().Add(time.Hour)})
+	kv.data["lock"] = string(b)
+	kv.mu.Unlock()
+
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatalf("expected lost lock")
+	}
+	if l.Held() {
+		t.Fatalf("unexpected held lock")
+	}
+	if err := l.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected ErrLockNotHeld, got %v", err)
+	}
+	if l.Lost() != nil {
+		t.Fatalf("expected nil lost channel after unlock")
+	}
+}
+
+func TestLocker_renew_error(t *testing.T) {
+	kv := newFakeKV()
+
+	var fail int32
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if atomic.LoadInt32(&fail) == 1 && path == "kv/cas/lock" {
+			return nil, fmt.Errorf("err1")
+		}
+		return kv.rf(path, contentType, body)
+	}}
+
+	l := NewLocker(m, "lock", &LockOptions{Owner: "o1", TTL: time.Millisecond * 300, RenewInterval: time.Millisecond * 100})
+
+	ok, err := l.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("expected acquired lock, got %v", err)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+
+	kv.mu.Lock()
+	v := lockValue{}
+	if err := json.Unmarshal([]byte(kv.data["lock"]), &v); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	kv.mu.Unlock()
+
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatalf("expected lost lock")
+	}
+	if !time.Now().Before(v.Expires) {
+		t.Fatalf("expected lost lock before the lease expiry %s", v.Expires)
+	}
+}
+
+func TestLocker_Lock(t *testing.T) {
+	kv := newFakeKV()
+
+	l1 := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o1"})
+	l2 := NewLocker(kv.module(), "lock", &LockOptions{Owner: "o2", RetryInterval: time.Millisecond * 5})
+
+	if err := l1.Lock(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	if err := l2.Lock(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = l1.Unlock()
+	}()
+
+	if err := l2.Lock(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestLocker_concurrent(t *testing.T) {
+	kv := newFakeKV()
+
+	var holders, maxHolders int32
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := NewLocker(kv.module(), "lock", &LockOptions{Owner: fmt.Sprintf("o%d", i), RetryInterval: time.Millisecond})
+			for j := 0; j < 3; j++ {
+				if err := l.Lock(context.Background()); err != nil {
+					t.Errorf("unexpected error, got %v", err)
+					return
+				}
+				n := atomic.AddInt32(&holders, 1)
+				if n > atomic.LoadInt32(&maxHolders) {
+					atomic.StoreInt32(&maxHolders, n)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				if err := l.Unlock(); err != nil {
+					t.Errorf("unexpected error, got %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Fatalf("unexpected lock holders count, got %d", maxHolders)
+	}
+}
+
+func TestLeaderElector(t *testing.T) {
+	kv := newFakeKV()
+
+	var started, stopped int32
+
+	newElector := func(owner string) *LeaderElector {
+		return NewLeaderElector(kv.module(), "leader", LeaderElectorOptions{
+			Lock: &LockOptions{Owner: owner, RetryInterval: time.Millisecond * 5},
+			OnStartedLeading: func(ctx context.Context) {
+				atomic.AddInt32(&started, 1)
+				<-ctx.Done()
+			},
+			OnStoppedLeading: func() {
+				atomic.AddInt32(&stopped, 1)
+			},
+		})
+	}
+
+	e1 := newElector("o1")
+	e2 := newElector("o2")
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	done1 := make(chan struct{})
+	go func() {
+		e1.Run(ctx1)
+		close(done1)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !e1.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !e1.IsLeader() {
+		t.Fatalf("expected leader")
+	}
+
+	go e2.Run(ctx2)
+	time.Sleep(time.Millisecond * 30)
+	if e2.IsLeader() {
+		t.Fatalf("unexpected second leader")
+	}
+
+	cancel1()
+	<-done1
+
+	deadline = time.Now().Add(time.Second)
+	for !e2.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !e2.IsLeader() {
+		t.Fatalf("expected new leader")
+	}
+	// OnStartedLeading is called in the goroutine
+	deadline = time.Now().Add(time.Second)
+	for atomic.LoadInt32(&started) != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s, st := atomic.LoadInt32(&started), atomic.LoadInt32(&stopped); s != 2 || st != 1 {
+		t.Fatalf("unexpected callbacks count, started %d, stopped %d", s, st)
+	}
+}
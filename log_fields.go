@@ -0,0 +1,177 @@
+package coreapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// LogLevel is the level of the log message
+type LogLevel string
+
+// Log levels
+const (
+	LogLevelError LogLevel = "error"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelDebug LogLevel = "debug"
+)
+
+// LogBadKey is the key of the value without the key, e.g. the last of the odd number of arguments
+const LogBadKey = "!BADKEY"
+
+// Validate checks the log level.
+func (level LogLevel) Validate() error {
+	switch level {
+	case LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug:
+		return nil
+	}
+	return fmt.Errorf("unknown log level %q", string(level))
+}
+
+// Log sends a message to the log with the level.
+func (l ModuleLog) Log(level LogLevel, message string) error {
+	if err := level.Validate(); err != nil {
+		return err
+	}
+	_, err := l.rf("log/"+string(level), "text/plain", []byte(message))
+	return err
+}
+
+// Logw sends a message with the fields to the log with the level.
+// The fields are the key and value pairs, e.g. Logw(LogLevelInfo, "done", "host", h, "latency", d).
+// The message and the fields are sent as the JSON object {"message": ..., "fields": {...}}.
+func (l ModuleLog) Logw(level LogLevel, message string, keysAndValues ...interface{}) error {
+	return l.logFields(level, message, LogFields(keysAndValues...))
+}
+
+// Errorw sends a message with the fields to the log with the Error level.
+func (l ModuleLog) Errorw(message string, keysAndValues ...interface{}) error {
+	return l.Logw(LogLevelError, message, keysAndValues...)
+}
+
+// Warnw sends a message with the fields to the log with the Warn level.
+func (l ModuleLog) Warnw(message string, keysAndValues ...interface{}) error {
+	return l.Logw(LogLevelWarn, message, keysAndValues...)
+}
+
+// Infow sends a message with the fields to the log with the Info level.
+func (l ModuleLog) Infow(message string, keysAndValues ...interface{}) error {
+	return l.Logw(LogLevelInfo, message, keysAndValues...)
+}
+
+// Debugw sends a message with the fields to the log with the Debug level.
+func (l ModuleLog) Debugw(message string, keysAndValues ...interface{}) error {
+	return l.Logw(LogLevelDebug, message, keysAndValues...)
+}
+
+// logFields sends the message with the fields. The message without the fields is sent as plain text.
+func (l ModuleLog) logFields(level LogLevel, message string, fields map[string]interface{}) error {
+	if err := level.Validate(); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return l.Log(level, message)
+	}
+
+	req := struct {
+		Message string                 `json:"message"`
+		Fields  map[string]interface{} `json:"fields"`
+	}{
+		Message: message,
+		Fields:  fields,
+	}
+
+	payload, errMarshal := json.Marshal(req)
+	if errMarshal != nil {
+		return fmt.Errorf("request marshal error, %w", errMarshal)
+	}
+
+	_, err := l.rf("log/"+string(level), "application/json", payload)
+	return err
+}
+
+// LogFields converts the key and value pairs to the log fields.
+// The keys which are not strings are formatted with fmt, the value without the key is stored with LogBadKey.
+// The values are converted with LogFieldValue. The last value of the duplicated key wins.
+func LogFields(keysAndValues ...interface{}) map[string]interface{} {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	fields := make(map[string]interface{}, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields[LogBadKey] = LogFieldValue(keysAndValues[i])
+			break
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		fields[key] = LogFieldValue(keysAndValues[i+1])
+	}
+	return fields
+}
+
+// LogFieldValue converts the value to the value with the deterministic JSON encoding:
+//   - errors, fmt.Stringer and encoding.TextMarshaler values are converted to strings;
+//   - time.Time is formatted with RFC3339Nano and time.Duration as the Go duration, e.g. "1.5s";
+//   - []byte is converted to a string, NaN and infinite floats to "NaN", "+Inf" and "-Inf";
+//   - booleans, numbers, strings, json.Marshaler values and the JSON encodable maps, slices and structs are kept;
+//   - other values are formatted with fmt.
+func LogFieldValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return value
+	case float32:
+		return logFloat(float64(value))
+	case float64:
+		return logFloat(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case time.Duration:
+		return value.String()
+	case []byte:
+		return string(value)
+	case error:
+		return value.Error()
+	case json.Marshaler:
+		return value
+	case fmt.Stringer:
+		return value.String()
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return fmt.Sprintf("%+v", value)
+		}
+		return string(text)
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr:
+		if _, err := json.Marshal(v); err == nil {
+			return v
+		}
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.String:
+		return v
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+func logFloat(v float64) interface{} {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return v
+}
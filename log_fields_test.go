@@ -0,0 +1,133 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestModuleLog_Infow(t *testing.T) {
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path != "log/info" {
+			t.Fatalf("unexpected path value, got %s", path)
+		}
+		if contentType != "application/json" {
+			t.Fatalf("unexpected contentType value, got %s", contentType)
+		}
+		expect := `{"message":"done","fields":{"err":"err1","host":"h1","latency":"1.5s","ok":true,"rows":10}}`
+		if string(body) != expect {
+			t.Fatalf("unexpected body value, got %s", string(body))
+		}
+
+		return nil, fmt.Errorf("err2")
+	}}
+
+	err := m.Infow("done", "host", "h1", "latency", 1500*time.Millisecond, "rows", 10, "ok", true, "err", errors.New("err1"))
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "err2" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleLog_Logw_levels(t *testing.T) {
+	var paths []string
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		paths = append(paths, path)
+		return nil, nil
+	}}
+
+	for _, f := range []func(string, ...interface{}) error{m.Errorw, m.Warnw, m.Infow, m.Debugw} {
+		if err := f("m", "k", "v"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+	if fmt.Sprint(paths) != "[log/error log/warn log/info log/debug]" {
+		t.Fatalf("unexpected paths, got %v", paths)
+	}
+
+	err := m.Logw("fatal", "m")
+	if err == nil || err.Error() != `unknown log level "fatal"` {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestModuleLog_Logw_without_fields(t *testing.T) {
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path != "log/warn" {
+			t.Fatalf("unexpected path value, got %s", path)
+		}
+		if contentType != "text/plain" {
+			t.Fatalf("unexpected contentType value, got %s", contentType)
+		}
+		if string(body) != "message" {
+			t.Fatalf("unexpected body value, got %s", string(body))
+		}
+		return nil, nil
+	}}
+
+	if err := m.Warnw("message"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	if f := LogFields(); f != nil {
+		t.Fatalf("unexpected fields, got %v", f)
+	}
+
+	f := LogFields("a", 1, 2, "b", "a", 3, "c")
+	if fmt.Sprint(f) != "map[!BADKEY:c 2:b a:3]" {
+		t.Fatalf("unexpected fields, got %v", f)
+	}
+}
+
+type logStringer struct{}
+
+func (logStringer) String() string { return "stringer" }
+
+func TestLogFieldValue(t *testing.T) {
+	ts := time.Date(2022, 8, 15, 10, 0, 0, 5, time.UTC)
+	ch := make(chan int)
+
+	for _, c := range []struct {
+		in     interface{}
+		expect interface{}
+	}{
+		{nil, nil},
+		{"s", "s"},
+		{int64(5), int64(5)},
+		{1.5, 1.5},
+		{float32(0.5), 0.5},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{ts, "2022-08-15T10:00:00.000000005Z"},
+		{time.Minute, "1m0s"},
+		{[]byte("b"), "b"},
+		{errors.New("e"), "e"},
+		{logStringer{}, "stringer"},
+		{net.ParseIP("127.0.0.1"), "127.0.0.1"},
+		{LogLevelInfo, LogLevelInfo},
+		{ch, fmt.Sprintf("%+v", ch)},
+		{map[string]float64{"a": math.NaN()}, "map[a:NaN]"},
+	} {
+		v := LogFieldValue(c.in)
+		if v != c.expect {
+			t.Fatalf("unexpected value for %v, got %#v", c.in, v)
+		}
+	}
+
+	m := map[string]int{"a": 1}
+	if v := LogFieldValue(m); fmt.Sprint(v) != "map[a:1]" {
+		t.Fatalf("unexpected value, got %v", v)
+	}
+	s := struct{ A int }{A: 1}
+	if v := LogFieldValue(s); v != s {
+		t.Fatalf("unexpected value, got %v", v)
+	}
+}
@@ -0,0 +1,341 @@
+package coreapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogEntry is a log message for the batch call
+type LogEntry struct {
+	Level   LogLevel               `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Batch sends the log entries in one request, if the balerter server supports it.
+// Otherwise, the entries are sent one by one and the first error is returned.
+func (l ModuleLog) Batch(entries []LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for _, e := range entries {
+		if err := e.Level.Validate(); err != nil {
+			return err
+		}
+	}
+	err := l.batch(entries)
+	if !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+	_, err = l.sendEach(entries)
+	return err
+}
+
+// batch sends the entries with one request to the balerter server
+func (l ModuleLog) batch(entries []LogEntry) error {
+	payload, errMarshal := json.Marshal(entries)
+	if errMarshal != nil {
+		return fmt.Errorf("request marshal error, %w", errMarshal)
+	}
+	if _, err := l.rf("log/batch", "application/json", payload); err != nil {
+		return fmt.Errorf("failed to call log/batch: %w", err)
+	}
+	return nil
+}
+
+// sendEach sends the entries one by one. It returns the number of the failed entries and the first error.
+func (l ModuleLog) sendEach(entries []LogEntry) (int, error) {
+	var failed int
+	var first error
+	for _, e := range entries {
+		if err := l.logFields(e.Level, e.Message, e.Fields); err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return failed, first
+}
+
+// LogDropPolicy defines the LogShipper behavior, when the queue is full
+type LogDropPolicy int
+
+// Log drop policies
+const (
+	// LogDropOldest drops the oldest queued entry to queue the new one
+	LogDropOldest LogDropPolicy = iota
+	// LogDropNewest drops the new entry
+	LogDropNewest
+	// LogBlock blocks the caller until the queue has room or the shipper is closed
+	LogBlock
+)
+
+// Log shipper defaults
+const (
+	DefaultLogQueueSize     = 1000
+	DefaultLogBatchSize     = 100
+	DefaultLogFlushInterval = time.Second
+)
+
+// ErrLogShipperClosed is returned for the entries queued after the shipper is closed
+var ErrLogShipperClosed = errors.New("log shipper is closed")
+
+// LogShipperOptions are options for the LogShipper. Zero values mean the defaults.
+type LogShipperOptions struct {
+	// QueueSize is the maximal number of the queued entries, DefaultLogQueueSize by default
+	QueueSize int
+	// BatchSize is the maximal number of the entries in one request, DefaultLogBatchSize by default.
+	// The batch is sent as soon as it is full.
+	BatchSize int
+	// FlushInterval is the interval of sending the incomplete batch, DefaultLogFlushInterval by default
+	FlushInterval time.Duration
+	// DropPolicy is the behavior, when the queue is full, LogDropOldest by default
+	DropPolicy LogDropPolicy
+	// OnError is called on the failed request, it may be nil
+	OnError func(err error)
+}
+
+// LogShipperStats are the LogShipper counters
+type LogShipperStats struct {
+	// Queued is the number of the accepted entries
+	Queued uint64
+	// Sent is the number of the sent entries
+	Sent uint64
+	// Dropped is the number of the entries dropped by the drop policy or the Close timeout
+	Dropped uint64
+	// Failed is the number of the entries failed to send
+	Failed uint64
+}
+
+// LogShipper sends the log entries asynchronously in batches.
+// The batches are sent with one request, if the balerter server supports it, or one by one otherwise.
+type LogShipper struct {
+	log  ModuleLog
+	opts LogShipperOptions
+
+	mu      sync.Mutex
+	notFull *sync.Cond
+	queue   []LogEntry
+	closed  bool
+	stats   LogShipperStats
+
+	wake    chan struct{}
+	flush   chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	aborted int32
+	noBatch int32
+}
+
+// NewLogShipper creates the log shipper and starts sending. opts may be nil.
+// The shipper must be closed with Close to send the queued entries.
+func NewLogShipper(log ModuleLog, opts *LogShipperOptions) *LogShipper {
+	s := &LogShipper{
+		log:   log,
+		wake:  make(chan struct{}, 1),
+		flush: make(chan chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.QueueSize <= 0 {
+		s.opts.QueueSize = DefaultLogQueueSize
+	}
+	if s.opts.BatchSize <= 0 {
+		s.opts.BatchSize = DefaultLogBatchSize
+	}
+	if s.opts.BatchSize > s.opts.QueueSize {
+		s.opts.BatchSize = s.opts.QueueSize
+	}
+	if s.opts.FlushInterval <= 0 {
+		s.opts.FlushInterval = DefaultLogFlushInterval
+	}
+	s.notFull = sync.NewCond(&s.mu)
+
+	go s.run()
+
+	return s
+}
+
+// Log queues the message with the level.
+func (s *LogShipper) Log(level LogLevel, message string) error {
+	return s.Enqueue(LogEntry{Level: level, Message: message})
+}
+
+// Logw queues the message with the fields with the level. See ModuleLog.Logw.
+func (s *LogShipper) Logw(level LogLevel, message string, keysAndValues ...interface{}) error {
+	return s.Enqueue(LogEntry{Level: level, Message: message, Fields: LogFields(keysAndValues...)})
+}
+
+// Enqueue queues the entry. The full queue is handled by the drop policy, the dropped entry is not an error.
+func (s *LogShipper) Enqueue(e LogEntry) error {
+	if err := e.Level.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for !s.closed && len(s.queue) >= s.opts.QueueSize {
+		switch s.opts.DropPolicy {
+		case LogDropNewest:
+			s.stats.Dropped++
+			return nil
+		case LogBlock:
+			s.notFull.Wait()
+		default:
+			s.queue = s.queue[1:]
+			s.stats.Dropped++
+		}
+	}
+	if s.closed {
+		return ErrLogShipperClosed
+	}
+
+	s.queue = append(s.queue, e)
+	s.stats.Queued++
+
+	if len(s.queue) >= s.opts.BatchSize {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Flush sends the queued entries and waits until they are sent or the context is done.
+func (s *LogShipper) Flush(ctx context.Context) error {
+	req := make(chan struct{})
+	select {
+	case s.flush <- req:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops queueing, sends the queued entries and waits until they are sent or the context is done.
+// If the context is done, the rest of the entries are dropped.
+func (s *LogShipper) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+		s.notFull.Broadcast()
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		atomic.StoreInt32(&s.aborted, 1)
+		return ctx.Err()
+	}
+}
+
+// Stats returns the counters.
+func (s *LogShipper) Stats() LogShipperStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *LogShipper) run() {
+	defer close(s.done)
+
+	t := time.NewTicker(s.opts.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.wake:
+			s.sendBatches(false)
+		case <-t.C:
+			s.sendBatches(true)
+		case req := <-s.flush:
+			s.sendBatches(true)
+			close(req)
+		case <-s.stop:
+			s.sendBatches(true)
+			return
+		}
+	}
+}
+
+// sendBatches sends the queued entries in batches. Only the full batches are sent, if all is false.
+func (s *LogShipper) sendBatches(all bool) {
+	for {
+		s.mu.Lock()
+		if atomic.LoadInt32(&s.aborted) == 1 {
+			s.stats.Dropped += uint64(len(s.queue))
+			s.queue = nil
+			s.mu.Unlock()
+			return
+		}
+		n := len(s.queue)
+		if n == 0 || (!all && n < s.opts.BatchSize) {
+			s.mu.Unlock()
+			return
+		}
+		if n > s.opts.BatchSize {
+			n = s.opts.BatchSize
+		}
+		batch := make([]LogEntry, n)
+		copy(batch, s.queue)
+		s.queue = s.queue[n:]
+		s.notFull.Broadcast()
+		s.mu.Unlock()
+
+		failed := s.send(batch)
+
+		s.mu.Lock()
+		s.stats.Sent += uint64(len(batch) - failed)
+		s.stats.Failed += uint64(failed)
+		s.mu.Unlock()
+	}
+}
+
+// send sends the batch and returns the number of the failed entries
+func (s *LogShipper) send(batch []LogEntry) int {
+	if atomic.LoadInt32(&s.noBatch) == 0 {
+		err := s.log.batch(batch)
+		if err == nil {
+			return 0
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			s.onError(err)
+			return len(batch)
+		}
+		atomic.StoreInt32(&s.noBatch, 1)
+	}
+
+	failed, err := s.log.sendEach(batch)
+	if err != nil {
+		s.onError(err)
+	}
+	return failed
+}
+
+func (s *LogShipper) onError(err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
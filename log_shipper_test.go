@@ -0,0 +1,231 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logBatchRecorder struct {
+	mu        sync.Mutex
+	calls     []string
+	noBatch   bool
+	err       error
+	block     chan struct{}
+	blockOnce sync.Once
+	started   chan struct{}
+}
+
+func (r *logBatchRecorder) rf(path, contentType string, body []byte) ([]byte, error) {
+	if r.block != nil {
+		r.blockOnce.Do(func() { close(r.started) })
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path == "log/batch" && r.noBatch {
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+	}
+	r.calls = append(r.calls, path+" "+string(body))
+	return nil, r.err
+}
+
+func (r *logBatchRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestModuleLog_Batch(t *testing.T) {
+	r := &logBatchRecorder{}
+	m := ModuleLog{rf: r.rf}
+
+	err := m.Batch([]LogEntry{{Level: LogLevelInfo, Message: "a"}, {Level: LogLevelError, Message: "b", Fields: map[string]interface{}{"k": 1}}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	expect := `[log/batch [{"level":"info","message":"a"},{"level":"error","message":"b","fields":{"k":1}}]]`
+	if fmt.Sprint(r.get()) != expect {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+
+	err = m.Batch([]LogEntry{{Level: "fatal"}})
+	if err == nil || err.Error() != `unknown log level "fatal"` {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+	if err := m.Batch(nil); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestModuleLog_Batch_not_supported(t *testing.T) {
+	r := &logBatchRecorder{noBatch: true}
+	m := ModuleLog{rf: r.rf}
+
+	if err := m.Batch([]LogEntry{{Level: LogLevelInfo, Message: "a"}, {Level: LogLevelWarn, Message: "b"}}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if fmt.Sprint(r.get()) != "[log/info a log/warn b]" {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+
+	r.err = fmt.Errorf("err1")
+	err := m.Batch([]LogEntry{{Level: LogLevelInfo, Message: "a"}})
+	if err == nil || err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestLogShipper(t *testing.T) {
+	r := &logBatchRecorder{}
+	s := NewLogShipper(ModuleLog{rf: r.rf}, &LogShipperOptions{BatchSize: 2, FlushInterval: time.Hour})
+
+	s.Log(LogLevelInfo, "a")
+	s.Logw(LogLevelWarn, "b", "k", "v")
+	s.Log(LogLevelDebug, "c")
+
+	deadline := time.Now().Add(time.Second)
+	for len(r.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	expect := `[log/batch [{"level":"info","message":"a"},{"level":"warn","message":"b","fields":{"k":"v"}}] log/batch [{"level":"debug","message":"c"}]]`
+	if fmt.Sprint(r.get()) != expect {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if err := s.Log(LogLevelInfo, "d"); err != ErrLogShipperClosed {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if st := s.Stats(); st != (LogShipperStats{Queued: 3, Sent: 3}) {
+		t.Fatalf("unexpected stats, got %+v", st)
+	}
+}
+
+func TestLogShipper_interval_and_fallback(t *testing.T) {
+	r := &logBatchRecorder{noBatch: true}
+	var errs []error
+	s := NewLogShipper(ModuleLog{rf: r.rf}, &LogShipperOptions{FlushInterval: 10 * time.Millisecond, OnError: func(err error) {
+		errs = append(errs, err)
+	}})
+
+	s.Log(LogLevelInfo, "a")
+	deadline := time.Now().Add(time.Second)
+	for len(r.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fmt.Sprint(r.get()) != "[log/info a]" {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+
+	r.mu.Lock()
+	r.err = fmt.Errorf("err1")
+	r.mu.Unlock()
+	s.Log(LogLevelInfo, "b")
+	s.Close(context.Background())
+
+	if st := s.Stats(); st != (LogShipperStats{Queued: 2, Sent: 1, Failed: 1}) {
+		t.Fatalf("unexpected stats, got %+v", st)
+	}
+	if fmt.Sprint(errs) != "[err1]" {
+		t.Fatalf("unexpected errors, got %v", errs)
+	}
+}
+
+// blockedShipper returns the shipper with the worker blocked on the request of the entry "0"
+func blockedShipper(t *testing.T, policy LogDropPolicy) (*LogShipper, *logBatchRecorder) {
+	r := &logBatchRecorder{block: make(chan struct{}), started: make(chan struct{})}
+	s := NewLogShipper(ModuleLog{rf: r.rf}, &LogShipperOptions{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, DropPolicy: policy})
+	s.Log(LogLevelInfo, "0")
+	select {
+	case <-r.started:
+	case <-time.After(time.Second):
+		t.Fatalf("the request is not started")
+	}
+	return s, r
+}
+
+func TestLogShipper_drop_policy(t *testing.T) {
+	for policy, expect := range map[LogDropPolicy]string{
+		LogDropOldest: `[log/batch [{"level":"info","message":"0"}] log/batch [{"level":"info","message":"2"}] log/batch [{"level":"info","message":"3"}]]`,
+		LogDropNewest: `[log/batch [{"level":"info","message":"0"}] log/batch [{"level":"info","message":"1"}] log/batch [{"level":"info","message":"2"}]]`,
+	} {
+		s, r := blockedShipper(t, policy)
+		for _, m := range []string{"1", "2", "3"} {
+			if err := s.Log(LogLevelInfo, m); err != nil {
+				t.Fatalf("unexpected error, got %v", err)
+			}
+		}
+		close(r.block)
+		s.Close(context.Background())
+
+		if fmt.Sprint(r.get()) != expect {
+			t.Fatalf("unexpected calls for policy %d, got %q", policy, r.get())
+		}
+		if st := s.Stats(); st != (LogShipperStats{Queued: 4 - uint64(policy), Sent: 3, Dropped: 1}) {
+			t.Fatalf("unexpected stats for policy %d, got %+v", policy, st)
+		}
+	}
+}
+
+func TestLogShipper_block(t *testing.T) {
+	s, r := blockedShipper(t, LogBlock)
+	s.Log(LogLevelInfo, "1")
+	s.Log(LogLevelInfo, "2")
+
+	done := make(chan error)
+	go func() {
+		done <- s.Log(LogLevelInfo, "3")
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("unexpected enqueue of the full queue, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(r.block)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	s.Close(context.Background())
+
+	if st := s.Stats(); st != (LogShipperStats{Queued: 4, Sent: 4}) {
+		t.Fatalf("unexpected stats, got %+v", st)
+	}
+}
+
+func TestLogShipper_close_timeout(t *testing.T) {
+	s, r := blockedShipper(t, LogDropOldest)
+	s.Log(LogLevelInfo, "1")
+	s.Log(LogLevelInfo, "2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+	if err := s.Flush(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+
+	close(r.block)
+	<-s.done
+
+	if fmt.Sprint(r.get()) != `[log/batch [{"level":"info","message":"0"}]]` {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+	if st := s.Stats(); st != (LogShipperStats{Queued: 3, Sent: 1, Dropped: 2}) {
+		t.Fatalf("unexpected stats, got %+v", st)
+	}
+}
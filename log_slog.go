@@ -0,0 +1,252 @@
+//go:build go1.21
+
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SlogHandlerOptions are options for the SlogHandler
+type SlogHandlerOptions struct {
+	// Level is the minimal level of the records, slog.LevelInfo by default
+	Level slog.Leveler
+	// BufferSize enables the asynchronous sending with the buffer of the size.
+	// The records are sent synchronously if it is zero.
+	BufferSize int
+	// Fallback receives the records, which are not sent to the balerter, in the slog text format.
+	// The records are not sent if the buffer is full, the handler is closed or the request fails.
+	Fallback io.Writer
+}
+
+// ErrSlogHandlerClosed is returned for the records handled after the handler is closed
+var ErrSlogHandlerClosed = fmt.Errorf("slog handler is closed")
+
+// SlogHandler is the slog.Handler, which sends the records to the log module.
+// The records are mapped to the log levels by the slog level, the attributes are sent as the log fields.
+// The attributes in the groups have the keys prefixed with the group names, e.g. "request.method".
+//
+// The handler is built with Go 1.21 or newer only, so the module still supports Go 1.18.
+// The go1.21 build constraint sets the language version of the file as well.
+type SlogHandler struct {
+	shared *slogShared
+	prefix string
+	fields map[string]interface{}
+}
+
+type slogShared struct {
+	log      ModuleLog
+	level    slog.Leveler
+	fallback *slog.TextHandler
+
+	mu     sync.RWMutex
+	queue  chan slogEntry
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type slogEntry struct {
+	time    time.Time
+	level   slog.Level
+	message string
+	fields  map[string]interface{}
+}
+
+// NewSlogHandler creates the slog handler for the log module. opts may be nil.
+// The asynchronous handler must be closed with Close to send the buffered records.
+func NewSlogHandler(log ModuleLog, opts *SlogHandlerOptions) *SlogHandler {
+	if opts == nil {
+		opts = &SlogHandlerOptions{}
+	}
+	s := &slogShared{
+		log:   log,
+		level: opts.Level,
+	}
+	if s.level == nil {
+		s.level = slog.LevelInfo
+	}
+	if opts.Fallback != nil {
+		s.fallback = slog.NewTextHandler(opts.Fallback, &slog.HandlerOptions{Level: slog.LevelDebug - 100})
+	}
+	if opts.BufferSize > 0 {
+		s.queue = make(chan slogEntry, opts.BufferSize)
+		s.wg.Add(1)
+		go s.run()
+	}
+	return &SlogHandler{shared: s}
+}
+
+// Enabled reports whether the level is not less than the handler level.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.shared.level.Level()
+}
+
+// Handle sends the record. The asynchronous handler returns an error only if the record is lost.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]interface{}, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, a)
+		return true
+	})
+
+	e := slogEntry{
+		time:    r.Time,
+		level:   r.Level,
+		message: r.Message,
+		fields:  fields,
+	}
+
+	s := h.shared
+	if s.queue == nil {
+		return s.send(e)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return s.fail(e, ErrSlogHandlerClosed)
+	}
+	select {
+	case s.queue <- e:
+		return nil
+	default:
+		return s.fail(e, fmt.Errorf("slog handler buffer is full"))
+	}
+}
+
+// WithAttrs returns the handler with the attributes added to all records.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(map[string]interface{}, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, a := range attrs {
+		addSlogAttr(fields, h.prefix, a)
+	}
+	return &SlogHandler{shared: h.shared, prefix: h.prefix, fields: fields}
+}
+
+// WithGroup returns the handler with the group for the following attributes.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{shared: h.shared, prefix: h.prefix + name + ".", fields: h.fields}
+}
+
+// Close sends the buffered records and stops the asynchronous sending. It is shared by the derived handlers.
+// The records handled after the close are written to the fallback.
+func (h *SlogHandler) Close() error {
+	s := h.shared
+	s.mu.Lock()
+	if s.closed || s.queue == nil {
+		s.closed = true
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *slogShared) run() {
+	defer s.wg.Done()
+	for e := range s.queue {
+		_ = s.send(e)
+	}
+}
+
+// send sends the entry, the failed entry is written to the fallback
+func (s *slogShared) send(e slogEntry) error {
+	err := s.log.logFields(slogLogLevel(e.level), e.message, e.fields)
+	if err != nil {
+		return s.fail(e, err)
+	}
+	return nil
+}
+
+// fail writes the entry to the fallback. It returns err if there is no fallback.
+func (s *slogShared) fail(e slogEntry, err error) error {
+	if s.fallback == nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(e.fields))
+	for k := range e.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := slog.NewRecord(e.time, e.level, e.message, 0)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, e.fields[k]))
+	}
+	return s.fallback.Handle(context.Background(), r)
+}
+
+// slogLogLevel maps the slog level to the log level
+func slogLogLevel(level slog.Level) LogLevel {
+	switch {
+	case level >= slog.LevelError:
+		return LogLevelError
+	case level >= slog.LevelWarn:
+		return LogLevelWarn
+	case level >= slog.LevelInfo:
+		return LogLevelInfo
+	}
+	return LogLevelDebug
+}
+
+// addSlogAttr adds the attribute to the fields with the prefix. The groups are flattened with the dotted keys.
+func addSlogAttr(fields map[string]interface{}, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addSlogAttr(fields, groupPrefix, ga)
+		}
+		return
+	}
+
+	var v interface{}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		v = a.Value.String()
+	case slog.KindInt64:
+		v = a.Value.Int64()
+	case slog.KindUint64:
+		v = a.Value.Uint64()
+	case slog.KindFloat64:
+		v = LogFieldValue(a.Value.Float64())
+	case slog.KindBool:
+		v = a.Value.Bool()
+	case slog.KindDuration:
+		v = a.Value.Duration().String()
+	case slog.KindTime:
+		v = a.Value.Time().Format(time.RFC3339Nano)
+	default:
+		v = LogFieldValue(a.Value.Any())
+	}
+	fields[prefix+a.Key] = v
+}
@@ -0,0 +1,141 @@
+//go:build go1.21
+
+package coreapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type slogRecorder struct {
+	mu    sync.Mutex
+	calls []string
+	err   error
+	block chan struct{}
+}
+
+func (r *slogRecorder) rf(path, contentType string, body []byte) ([]byte, error) {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, path+" "+string(body))
+	return nil, r.err
+}
+
+func (r *slogRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestSlogHandler(t *testing.T) {
+	r := &slogRecorder{}
+	logger := slog.New(NewSlogHandler(ModuleLog{rf: r.rf}, &SlogHandlerOptions{Level: slog.LevelDebug}))
+
+	logger.Debug("d")
+	logger.Info("i", "host", "h1", "latency", 1500*time.Millisecond, slog.Group("req", "method", "GET", slog.Int("code", 200)))
+	logger.With("app", "api").WithGroup("db").With("name", "main").Warn("w", "rows", 10, slog.Group("", "inline", true), slog.Group("empty"))
+	logger.Log(context.Background(), slog.LevelError+4, "e", "ratio", 0.5, "at", time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC))
+
+	expect := []string{
+		"log/debug d",
+		`log/info {"message":"i","fields":{"host":"h1","latency":"1.5s","req.code":200,"req.method":"GET"}}`,
+		`log/warn {"message":"w","fields":{"app":"api","db.inline":true,"db.name":"main","db.rows":10}}`,
+		`log/error {"message":"e","fields":{"at":"2022-08-15T00:00:00Z","ratio":0.5}}`,
+	}
+	if fmt.Sprint(r.get()) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+}
+
+func TestSlogHandler_level(t *testing.T) {
+	r := &slogRecorder{}
+	logger := slog.New(NewSlogHandler(ModuleLog{rf: r.rf}, nil))
+
+	logger.Debug("d")
+	logger.Info("i")
+
+	if fmt.Sprint(r.get()) != "[log/info i]" {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+}
+
+func TestSlogHandler_fallback(t *testing.T) {
+	r := &slogRecorder{err: fmt.Errorf("err1")}
+	h := NewSlogHandler(ModuleLog{rf: r.rf}, nil)
+
+	rec := slog.NewRecord(time.Time{}, slog.LevelInfo, "i", 0)
+	err := h.Handle(context.Background(), rec)
+	if err == nil || err.Error() != "err1" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+
+	b := &bytes.Buffer{}
+	h = NewSlogHandler(ModuleLog{rf: r.rf}, &SlogHandlerOptions{Fallback: b})
+	rec.AddAttrs(slog.String("b", "2"), slog.String("a", "1 2"))
+	if err := h.WithGroup("g").Handle(context.Background(), rec); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if b.String() != "level=INFO msg=i g.a=\"1 2\" g.b=2\n" {
+		t.Fatalf("unexpected fallback value, got %s", b.String())
+	}
+}
+
+func TestSlogHandler_async(t *testing.T) {
+	r := &slogRecorder{block: make(chan struct{})}
+	b := &bytes.Buffer{}
+	h := NewSlogHandler(ModuleLog{rf: r.rf}, &SlogHandlerOptions{BufferSize: 1, Fallback: b})
+	logger := slog.New(h)
+
+	logger.Info("1")
+	// wait the worker takes the first record and blocks on the request
+	deadline := time.Now().Add(time.Second)
+	for len(h.shared.queue) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Info("2")
+	logger.Info("3")
+
+	close(r.block)
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	logger.Info("4")
+
+	if fmt.Sprint(r.get()) != "[log/info 1 log/info 2]" {
+		t.Fatalf("unexpected calls, got %q", r.get())
+	}
+	if !bytes.Contains(b.Bytes(), []byte("msg=3")) || !bytes.Contains(b.Bytes(), []byte("msg=4")) {
+		t.Fatalf("unexpected fallback value, got %s", b.String())
+	}
+
+	h = NewSlogHandler(ModuleLog{rf: r.rf}, &SlogHandlerOptions{BufferSize: 1})
+	h.Close()
+	err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "i", 0))
+	if err != ErrSlogHandlerClosed {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestSlogLogLevel(t *testing.T) {
+	for level, expect := range map[slog.Level]LogLevel{
+		slog.LevelDebug - 1: LogLevelDebug,
+		slog.LevelDebug:     LogLevelDebug,
+		slog.LevelInfo:      LogLevelInfo,
+		slog.LevelInfo + 1:  LogLevelInfo,
+		slog.LevelWarn:      LogLevelWarn,
+		slog.LevelError:     LogLevelError,
+		slog.LevelError + 4: LogLevelError,
+	} {
+		if l := slogLogLevel(level); l != expect {
+			t.Fatalf("unexpected level for %s, got %s", level, l)
+		}
+	}
+}
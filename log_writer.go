@@ -0,0 +1,92 @@
+package coreapi
+
+import (
+	"bytes"
+	"log"
+	"sync"
+)
+
+// LogWriterMaxLineLength is the maximal length of the buffered partial line.
+// The longer line is sent in parts.
+const LogWriterMaxLineLength = 64 * 1024
+
+// LogWriter is the io.Writer, which sends each line to the log module with the level.
+// The partial line is buffered until the line end, Flush or Close. The empty lines are skipped.
+type LogWriter struct {
+	log   ModuleLog
+	level LogLevel
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Writer returns the io.Writer, which sends each written line to the log with the level.
+func (l ModuleLog) Writer(level LogLevel) *LogWriter {
+	return &LogWriter{log: l, level: level}
+}
+
+// NewLogger returns the standard logger, which sends each message to the log with the level.
+// The prefix and the flag are the log.New arguments.
+func (l ModuleLog) NewLogger(level LogLevel, prefix string, flag int) *log.Logger {
+	return log.New(l.Writer(level), prefix, flag)
+}
+
+// Write sends the complete lines and buffers the rest. It returns the first send error,
+// the lines after the failed one are still sent.
+func (w *LogWriter) Write(p []byte) (int, error) {
+	if err := w.level.Validate(); err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	var first error
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.send(w.buf[:i]); err != nil && first == nil {
+			first = err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= LogWriterMaxLineLength {
+		if err := w.send(w.buf[:LogWriterMaxLineLength]); err != nil && first == nil {
+			first = err
+		}
+		w.buf = w.buf[LogWriterMaxLineLength:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+
+	return len(p), first
+}
+
+// Flush sends the buffered partial line.
+func (w *LogWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := w.buf
+	w.buf = nil
+	return w.send(line)
+}
+
+// Close sends the buffered partial line.
+func (w *LogWriter) Close() error {
+	return w.Flush()
+}
+
+// send sends the line without the trailing carriage return
+func (w *LogWriter) send(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return nil
+	}
+	return w.log.Log(w.level, string(line))
+}
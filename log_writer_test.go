@@ -0,0 +1,100 @@
+package coreapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestLogWriter(t *testing.T) {
+	var calls []string
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		calls = append(calls, path+" "+string(body))
+		return nil, nil
+	}}
+
+	var w io.WriteCloser = m.Writer(LogLevelWarn)
+
+	n, err := w.Write([]byte("a\nb\r\n\nc"))
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if n != 7 {
+		t.Fatalf("unexpected n value, got %d", n)
+	}
+	w.Write([]byte("d"))
+	if fmt.Sprint(calls) != "[log/warn a log/warn b]" {
+		t.Fatalf("unexpected calls, got %q", calls)
+	}
+
+	w.Write([]byte("e\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	w.Write([]byte("f"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if fmt.Sprint(calls) != "[log/warn a log/warn b log/warn cde log/warn f]" {
+		t.Fatalf("unexpected calls, got %q", calls)
+	}
+}
+
+func TestLogWriter_long_line(t *testing.T) {
+	var lengths []int
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		lengths = append(lengths, len(body))
+		return nil, nil
+	}}
+
+	w := m.Writer(LogLevelInfo)
+	w.Write(bytes.Repeat([]byte("x"), LogWriterMaxLineLength*2+10))
+	w.Flush()
+
+	if fmt.Sprint(lengths) != fmt.Sprint([]int{LogWriterMaxLineLength, LogWriterMaxLineLength, 10}) {
+		t.Fatalf("unexpected lengths, got %v", lengths)
+	}
+}
+
+func TestLogWriter_error(t *testing.T) {
+	var calls []string
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		calls = append(calls, string(body))
+		return nil, fmt.Errorf("err %s", body)
+	}}
+
+	w := m.Writer(LogLevelError)
+	n, err := w.Write([]byte("a\nb\n"))
+	if n != 4 {
+		t.Fatalf("unexpected n value, got %d", n)
+	}
+	if err == nil || err.Error() != "err a" {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+	if fmt.Sprint(calls) != "[a b]" {
+		t.Fatalf("unexpected calls, got %q", calls)
+	}
+
+	_, err = m.Writer("fatal").Write([]byte("a\n"))
+	if err == nil || err.Error() != `unknown log level "fatal"` {
+		t.Fatalf("unexpected error value, got %v", err)
+	}
+}
+
+func TestModuleLog_NewLogger(t *testing.T) {
+	var calls []string
+	m := ModuleLog{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		calls = append(calls, path+" "+string(body))
+		return nil, nil
+	}}
+
+	var l *log.Logger = m.NewLogger(LogLevelInfo, "lib: ", 0)
+	l.Printf("started %d", 1)
+	l.Print("multi\nline")
+
+	if fmt.Sprint(calls) != "[log/info lib: started 1 log/info lib: multi log/info line]" {
+		t.Fatalf("unexpected calls, got %q", calls)
+	}
+}
@@ -0,0 +1,49 @@
+package coreapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the max length in bytes of the KV key, the alert name or the datasource name.
+const MaxNameLength = 1024
+
+// ErrInvalidName is returned for the illegal KV key, alert name or datasource name.
+var ErrInvalidName = errors.New("invalid name")
+
+// ValidateName reports, whether the name may be used as the KV key, the alert name or the datasource name.
+// The name should be non-empty valid UTF-8 string without control characters, up to MaxNameLength bytes,
+// and should not be "." or "..". The returned error wraps ErrInvalidName.
+//
+// Other characters, like '/', '?', '#' or spaces, are allowed and escaped in the request path.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidName)
+	case len(name) > MaxNameLength:
+		return fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidName, MaxNameLength)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w %q: name is not valid UTF-8", ErrInvalidName, name)
+	case name == "." || name == "..":
+		return fmt.Errorf("%w %q: name is a relative path", ErrInvalidName, name)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w %q: name contains control character %U", ErrInvalidName, name, r)
+		}
+	}
+
+	return nil
+}
+
+// escapeName validates the name and escapes it as the request path segment.
+func escapeName(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return url.PathEscape(name), nil
+}
@@ -0,0 +1,212 @@
+package coreapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"a", "a/b", "a?b#c", "with space", "юникод", "a%2Fb", "team-a:key", "..a"} {
+		if err := ValidateName(name); err != nil {
+			t.Fatalf("unexpected error for %q, got %v", name, err)
+		}
+	}
+
+	for name, expect := range map[string]string{
+		"":                                   "invalid name: name is empty",
+		strings.Repeat("a", MaxNameLength+1): "invalid name: name is longer than 1024 bytes",
+		"a\xffb":                             `invalid name "a\xffb": name is not valid UTF-8`,
+		".":                                  `invalid name ".": name is a relative path`,
+		"..":                                 `invalid name "..": name is a relative path`,
+		"a\nb":                               `invalid name "a\nb": name contains control character U+000A`,
+		"a\x00":                              `invalid name "a\x00": name contains control character U+0000`,
+	} {
+		err := ValidateName(name)
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", name)
+		}
+		if !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected ErrInvalidName, got %v", err)
+		}
+		if err.Error() != expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+}
+
+var hostileNames = map[string]string{
+	"a/b":        "a%2Fb",
+	"a?b=c":      "a%3Fb=c",
+	"a#b":        "a%23b",
+	"a b":        "a%20b",
+	"a%2Fb":      "a%252Fb",
+	"юникод":     "%D1%8E%D0%BD%D0%B8%D0%BA%D0%BE%D0%B4",
+	"../get/x":   "..%2Fget%2Fx",
+	"team-a:key": "team-a:key",
+}
+
+func TestModuleKV_escape(t *testing.T) {
+	for name, escaped := range hostileNames {
+		var paths []string
+
+		m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+			paths = append(paths, path)
+			if strings.HasPrefix(path, "kv/get/") {
+				return []byte(`"v"`), nil
+			}
+			return nil, nil
+		}}
+
+		if err := m.Put(name, "v"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if err := m.Upsert(name, "v"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if err := m.Delete(name); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Get(name); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		expect := []string{"kv/put/" + escaped, "kv/upsert/" + escaped, "kv/delete/" + escaped, "kv/get/" + escaped}
+		if strings.Join(paths, " ") != strings.Join(expect, " ") {
+			t.Fatalf("unexpected paths for %q, got %v", name, paths)
+		}
+	}
+}
+
+func TestModuleKV_escape_namespace(t *testing.T) {
+	kv := newFakeKV()
+
+	m := kv.module().Namespace("a/b")
+	if err := m.Put("c?d", "v"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if kv.data["a/b:c?d"] != "v" {
+		t.Fatalf("unexpected stored values, got %v", kv.data)
+	}
+}
+
+func TestModuleKV_invalid_name(t *testing.T) {
+	m := ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		t.Fatalf("unexpected call %s", path)
+		return nil, nil
+	}}
+
+	if err := m.Put("", "v"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if err := m.Upsert("a\n", "v"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if err := m.Delete(".."); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Get("\xff"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestModuleAlert_escape(t *testing.T) {
+	for name, escaped := range hostileNames {
+		var paths []string
+
+		m := ModuleAlert{rf: func(path, contentType string, body []byte) ([]byte, error) {
+			paths = append(paths, path)
+			if strings.HasPrefix(path, "alert/get/") {
+				return []byte(`{"name":"a"}`), nil
+			}
+			return []byte(`{"alert":{"name":"a"}}`), nil
+		}}
+
+		if _, _, err := m.Error(name, "m", &AlertOptions{Quiet: true}); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Get(name); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		expect := []string{"alert/error/" + escaped + "?quiet=true", "alert/get/" + escaped}
+		if strings.Join(paths, " ") != strings.Join(expect, " ") {
+			t.Fatalf("unexpected paths for %q, got %v", name, paths)
+		}
+	}
+}
+
+func TestModuleAlert_invalid_name(t *testing.T) {
+	m := ModuleAlert{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		t.Fatalf("unexpected call %s", path)
+		return nil, nil
+	}}
+
+	if _, _, err := m.Success("", "m", nil); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Get("a\tb"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestModuleDatasource_escape(t *testing.T) {
+	for name, escaped := range hostileNames {
+		var paths []string
+
+		m := ModuleDatasource{rf: func(path, contentType string, body []byte) ([]byte, error) {
+			paths = append(paths, path)
+			return nil, nil
+		}}
+
+		if _, err := m.MySQL(name).Query("q"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Postgres(name).Query("q"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Clickhouse(name).Query("q"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Loki(name).Query("q", nil); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if _, err := m.Loki(name).Range("q", nil); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		expect := []string{
+			"datasource/mysql/" + escaped + "/query",
+			"datasource/postgres/" + escaped + "/query",
+			"datasource/clickhouse/" + escaped + "/query",
+			"datasource/loki/" + escaped + "/query",
+			"datasource/loki/" + escaped + "/range",
+		}
+		if strings.Join(paths, " ") != strings.Join(expect, " ") {
+			t.Fatalf("unexpected paths for %q, got %v", name, paths)
+		}
+	}
+}
+
+func TestModuleDatasource_invalid_name(t *testing.T) {
+	m := ModuleDatasource{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		t.Fatalf("unexpected call %s", path)
+		return nil, nil
+	}}
+
+	if _, err := m.MySQL("").Query("q"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Postgres(".").Query("q"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Clickhouse("\x7f").Query("q"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Loki("").Query("q", nil); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Loki("").Range("q", nil); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package coreapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSilencePrefix          = "silence:"
+	defaultSilenceRefreshInterval = time.Second * 30
+)
+
+// Silence silences the alerts matched by the alert name and the fields.
+//
+// The silence is active between Start and End. Zero Start or End means the unbounded range.
+// If the Schedule is defined, the silence is active for the Duration after each schedule fire time.
+type Silence struct {
+	ID string `json:"id"`
+	// AlertName is the alert name glob pattern in the path.Match syntax
+	AlertName string `json:"alert_name"`
+	// Fields are glob patterns for the alert fields. All of them should match.
+	Fields map[string]string `json:"fields,omitempty"`
+	Start  time.Time         `json:"start,omitempty"`
+	End    time.Time         `json:"end,omitempty"`
+	// Schedule is the cron expression (minute, hour, day of month, month, day of week) of the recurring window start
+	Schedule string `json:"schedule,omitempty"`
+	// Duration is the recurring window duration
+	Duration time.Duration `json:"duration,omitempty"`
+	// Suppress drops the matched alert calls. Otherwise, the alerts are sent with the Quiet option.
+	Suppress bool   `json:"suppress,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+
+	// schedule is the parsed Schedule, it is set by the Silencer on the silences load
+	schedule *cronSchedule
+}
+
+// Validate checks the silence definition.
+func (s Silence) Validate() error {
+	if s.AlertName == "" {
+		return fmt.Errorf("silence alert name is empty")
+	}
+	if _, err := path.Match(s.AlertName, ""); err != nil {
+		return fmt.Errorf("bad silence alert name pattern %q: %w", s.AlertName, err)
+	}
+	for k, v := range s.Fields {
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("bad silence field %s pattern %q: %w", k, v, err)
+		}
+	}
+	if !s.Start.IsZero() && !s.End.IsZero() && s.End.Before(s.Start) {
+		return fmt.Errorf("silence end is before start")
+	}
+	if s.Schedule != "" {
+		if _, err := parseCron(s.Schedule); err != nil {
+			return err
+		}
+		if s.Duration <= 0 {
+			return fmt.Errorf("silence duration must be positive for the scheduled silence")
+		}
+	}
+	return nil
+}
+
+// Active returns true, if the silence is active at the time.
+func (s Silence) Active(t time.Time) bool {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		return false
+	}
+	if !s.End.IsZero() && !t.Before(s.End) {
+		return false
+	}
+	if s.Schedule == "" {
+		return true
+	}
+	c := s.schedule
+	if c == nil {
+		var err error
+		if c, err = parseCron(s.Schedule); err != nil {
+			return false
+		}
+	}
+	return c.activeWindow(t, s.Duration)
+}
+
+// Matches returns true, if the silence matches the alert name and the fields.
+func (s Silence) Matches(alertName string, fields map[string]string) bool {
+	if ok, _ := path.Match(s.AlertName, alertName); !ok {
+		return false
+	}
+	for k, pattern := range s.Fields {
+		v, exists := fields[k]
+		if !exists {
+			return false
+		}
+		if ok, _ := path.Match(pattern, v); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SilencerOptions are options for the Silencer.
+type SilencerOptions struct {
+	// Prefix is the KV key prefix for the silences. Default is "silence:".
+	Prefix string
+	// RefreshInterval is the interval of the silences reload from the KV. Default is 30 seconds.
+	RefreshInterval time.Duration
+	// Location is the time zone for the silence schedules. Default is time.Local.
+	Location *time.Location
+	// OnError is called on the failed silences reload, it may be nil
+	OnError func(err error)
+}
+
+// Silencer manages the alert silences. The silences are stored in the KV module, so they are shared between processes.
+// Use ModuleAlert.WithSilencer for apply the silences to the alert calls.
+type Silencer struct {
+	kv       ModuleKV
+	prefix   string
+	refresh  time.Duration
+	location *time.Location
+	onError  func(err error)
+	now      func() time.Time
+
+	mu       sync.Mutex
+	silences []Silence
+	loadedAt time.Time
+	loading  bool
+	// gen is incremented on the silences change, so the reload started before the change is not cached
+	gen uint64
+}
+
+// NewSilencer creates a new Silencer. opts may be nil.
+func NewSilencer(kv ModuleKV, opts *SilencerOptions) *Silencer {
+	s := &Silencer{
+		kv:       kv,
+		prefix:   defaultSilencePrefix,
+		refresh:  defaultSilenceRefreshInterval,
+		location: time.Local,
+		now:      time.Now,
+	}
+	if opts != nil {
+		if opts.Prefix != "" {
+			s.prefix = opts.Prefix
+		}
+		if opts.RefreshInterval > 0 {
+			s.refresh = opts.RefreshInterval
+		}
+		if opts.Location != nil {
+			s.location = opts.Location
+		}
+		s.onError = opts.OnError
+	}
+	return s
+}
+
+// Add stores the silence and returns its ID. The ID is generated, if it is empty.
+func (s *Silencer) Add(silence Silence) (string, error) {
+	if err := silence.Validate(); err != nil {
+		return "", err
+	}
+
+	if silence.ID == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate silence id: %w", err)
+		}
+		silence.ID = hex.EncodeToString(b)
+	}
+
+	v, err := json.Marshal(silence)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal silence: %w", err)
+	}
+
+	if err := s.kv.Upsert(s.prefix+silence.ID, string(v)); err != nil {
+		return "", fmt.Errorf("failed to store silence: %w", err)
+	}
+
+	s.invalidate()
+
+	return silence.ID, nil
+}
+
+// Remove deletes the silence.
+func (s *Silencer) Remove(id string) error {
+	if err := s.kv.Delete(s.prefix + id); err != nil {
+		return fmt.Errorf("failed to delete silence: %w", err)
+	}
+
+	s.invalidate()
+
+	return nil
+}
+
+// List returns all stored silences, sorted by the ID.
+func (s *Silencer) List() ([]Silence, error) {
+	all, err := s.kv.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load silences: %w", err)
+	}
+
+	var result []Silence
+	for k, v := range all {
+		if !strings.HasPrefix(k, s.prefix) {
+			continue
+		}
+		var silence Silence
+		if err := json.Unmarshal([]byte(v), &silence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal silence %s: %w", k, err)
+		}
+		if silence.Schedule != "" {
+			silence.schedule, _ = parseCron(silence.Schedule)
+		}
+		result = append(result, silence)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// Match returns the active silence, which matches the alert name and the fields, or nil.
+// The suppressing silences take precedence. Silences are reloaded from the KV after the refresh interval.
+//
+// The reload failure does not fail the match: the last loaded silences are used until the next reload,
+// and the error is reported to SilencerOptions.OnError. The alerts are not silenced until the silences are loaded.
+func (s *Silencer) Match(alertName string, fields map[string]string) *Silence {
+	now := s.now().In(s.location)
+
+	silences := s.load(now)
+
+	var match *Silence
+	for i := range silences {
+		silence := &silences[i]
+		if !silence.Active(now) || !silence.Matches(alertName, fields) {
+			continue
+		}
+		if silence.Suppress {
+			return silence
+		}
+		if match == nil {
+			match = silence
+		}
+	}
+
+	return match
+}
+
+// load returns the loaded silences and reloads them, if the refresh interval is passed.
+// The silences are reloaded by one caller without the lock, the others use the last loaded silences.
+func (s *Silencer) load(now time.Time) []Silence {
+	s.mu.Lock()
+	if s.loading || (!s.loadedAt.IsZero() && now.Sub(s.loadedAt) < s.refresh) {
+		silences := s.silences
+		s.mu.Unlock()
+		return silences
+	}
+	s.loading = true
+	gen := s.gen
+	s.mu.Unlock()
+
+	silences, err := s.List()
+
+	s.mu.Lock()
+	s.loading = false
+	if gen == s.gen {
+		// the failed reload is retried after the refresh interval as well
+		s.loadedAt = now
+	}
+	if err == nil {
+		s.silences = silences
+	}
+	silences = s.silences
+	s.mu.Unlock()
+
+	if err != nil && s.onError != nil {
+		s.onError(err)
+	}
+
+	return silences
+}
+
+func (s *Silencer) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.gen++
+	s.mu.Unlock()
+}
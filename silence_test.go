@@ -0,0 +1,306 @@
+package coreapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSilence_Validate(t *testing.T) {
+	for _, c := range []struct {
+		silence Silence
+		expect  string
+	}{
+		{silence: Silence{}, expect: "silence alert name is empty"},
+		{silence: Silence{AlertName: "["}, expect: `bad silence alert name pattern "[": syntax error in pattern`},
+		{silence: Silence{AlertName: "a", Fields: map[string]string{"f": "["}}, expect: `bad silence field f pattern "[": syntax error in pattern`},
+		{silence: Silence{AlertName: "a", Start: time.Unix(10, 0), End: time.Unix(5, 0)}, expect: "silence end is before start"},
+		{silence: Silence{AlertName: "a", Schedule: "x"}, expect: `cron expression "x" must have 5 fields`},
+		{silence: Silence{AlertName: "a", Schedule: "* * * * *"}, expect: "silence duration must be positive for the scheduled silence"},
+	} {
+		err := c.silence.Validate()
+		if err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		if err.Error() != c.expect {
+			t.Fatalf("unexpected error value, got %s", err.Error())
+		}
+	}
+
+	if err := (Silence{AlertName: "a*", Schedule: "0 22 * * *", Duration: time.Hour}).Validate(); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+}
+
+func TestSilence_Active(t *testing.T) {
+	now := time.Date(2022, 8, 15, 22, 30, 0, 0, time.UTC)
+
+	if !(Silence{}).Active(now) {
+		t.Fatalf("expected active silence without bounds")
+	}
+	if (Silence{Start: now.Add(time.Minute)}).Active(now) {
+		t.Fatalf("expected inactive silence before start")
+	}
+	if (Silence{End: now}).Active(now) {
+		t.Fatalf("expected inactive silence after end")
+	}
+	if !(Silence{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}).Active(now) {
+		t.Fatalf("expected active silence")
+	}
+	if !(Silence{Schedule: "0 22 * * *", Duration: time.Hour}).Active(now) {
+		t.Fatalf("expected active scheduled silence")
+	}
+	if (Silence{Schedule: "0 22 * * *", Duration: time.Minute * 10}).Active(now) {
+		t.Fatalf("expected inactive scheduled silence")
+	}
+}
+
+func TestSilence_Matches(t *testing.T) {
+	s := Silence{AlertName: "host-*", Fields: map[string]string{"dc": "eu-*"}}
+
+	if !s.Matches("host-1", map[string]string{"dc": "eu-west", "x": "y"}) {
+		t.Fatalf("expected match")
+	}
+	if s.Matches("db-1", map[string]string{"dc": "eu-west"}) {
+		t.Fatalf("unexpected match by the name")
+	}
+	if s.Matches("host-1", map[string]string{"dc": "us-east"}) {
+		t.Fatalf("unexpected match by the field value")
+	}
+	if s.Matches("host-1", nil) {
+		t.Fatalf("unexpected match without the field")
+	}
+}
+
+func TestSilencer_Add_List_Remove(t *testing.T) {
+	kv := newFakeKV()
+	kv.data["other"] = "value"
+
+	s := NewSilencer(kv.module(), nil)
+
+	id, err := s.Add(Silence{AlertName: "a", Comment: "deploy"})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(id) != 16 {
+		t.Fatalf("unexpected id value, got %s", id)
+	}
+
+	_, err = s.Add(Silence{ID: "fixed", AlertName: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("unexpected silences count, got %d", len(list))
+	}
+	if _, ok := kv.data["silence:fixed"]; !ok {
+		t.Fatalf("expected stored silence, got %v", kv.data)
+	}
+
+	if err := s.Remove(id); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	list, err = s.List()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "fixed" {
+		t.Fatalf("unexpected silences value, got %v", list)
+	}
+
+	if _, err := s.Add(Silence{}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestSilencer_List_error(t *testing.T) {
+	s := NewSilencer(ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}, nil)
+
+	_, err := s.List()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to load silences: err1" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+
+	kv := newFakeKV()
+	kv.data["silence:x"] = "bad"
+
+	_, err = NewSilencer(kv.module(), nil).List()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err.Error() != "failed to unmarshal silence silence:x: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestSilencer_Match(t *testing.T) {
+	kv := newFakeKV()
+
+	s := NewSilencer(kv.module(), &SilencerOptions{Prefix: "s/", RefreshInterval: time.Hour, Location: time.UTC})
+	now := time.Date(2022, 8, 15, 22, 30, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Add(Silence{ID: "1", AlertName: "a*"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, err := s.Add(Silence{ID: "2", AlertName: "ab", Suppress: true, Schedule: "0 22 * * *", Duration: time.Hour}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	m := s.Match("ab", nil)
+	if m == nil || m.ID != "2" {
+		t.Fatalf("unexpected match value, got %v", m)
+	}
+	if m.schedule == nil {
+		t.Fatalf("the schedule is not parsed on the silences load")
+	}
+
+	m = s.Match("ac", nil)
+	if m == nil || m.ID != "1" {
+		t.Fatalf("unexpected match value, got %v", m)
+	}
+
+	m = s.Match("b", nil)
+	if m != nil {
+		t.Fatalf("unexpected match value, got %v", m)
+	}
+
+	// the silences are cached until the refresh interval
+	delete(kv.data, "s/1")
+	m = s.Match("ac", nil)
+	if m == nil {
+		t.Fatalf("expected cached silence")
+	}
+	now = now.Add(time.Hour)
+	m = s.Match("ac", nil)
+	if m != nil {
+		t.Fatalf("unexpected match value, got %v", m)
+	}
+}
+
+func TestModuleAlert_WithSilencer(t *testing.T) {
+	kv := newFakeKV()
+	s := NewSilencer(kv.module(), nil)
+
+	if _, err := s.Add(Silence{ID: "1", AlertName: "quiet"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if _, err := s.Add(Silence{ID: "2", AlertName: "host", Fields: map[string]string{"dc": "eu"}, Suppress: true}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path)
+			return []byte(`{"level_was_updated":true,"alert":{"name":"x","level":3}}`), nil
+		},
+	}.WithSilencer(s)
+
+	_, _, err := m.Error("quiet", "m", nil)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	a, updated, err := m.Error("host", "m", &AlertOptions{Fields: map[string]string{"dc": "eu"}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if updated || a != nil {
+		t.Fatalf("unexpected suppressed alert value, got %v, %v", a, updated)
+	}
+
+	_, _, err = m.Error("host", "m", &AlertOptions{Fields: map[string]string{"dc": "us"}})
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	expect := []string{"alert/error/quiet?quiet=true", "alert/error/host?fields=dc%3Aus"}
+	if fmt.Sprint(calls) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+}
+
+func TestSilencer_Match_error(t *testing.T) {
+	kv := newFakeKV()
+
+	var errs []string
+	s := NewSilencer(kv.module(), &SilencerOptions{RefreshInterval: time.Minute, OnError: func(err error) {
+		errs = append(errs, err.Error())
+	}})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Add(Silence{ID: "1", AlertName: "a"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if m := s.Match("a", nil); m == nil {
+		t.Fatalf("expected match")
+	}
+
+	// the last loaded silences are used, if the reload fails
+	kv.data["silence:x"] = "bad"
+	now = now.Add(time.Minute)
+	if m := s.Match("a", nil); m == nil {
+		t.Fatalf("expected match")
+	}
+	if len(errs) != 1 || errs[0] != "failed to unmarshal silence silence:x: invalid character 'b' looking for beginning of value" {
+		t.Fatalf("unexpected errors value, got %v", errs)
+	}
+
+	// the failed reload is retried after the refresh interval
+	s.Match("a", nil)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors value, got %v", errs)
+	}
+	delete(kv.data, "silence:x")
+	delete(kv.data, "silence:1")
+	now = now.Add(time.Minute)
+	if m := s.Match("a", nil); m != nil {
+		t.Fatalf("unexpected match value, got %v", m)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors value, got %v", errs)
+	}
+}
+
+func TestModuleAlert_WithSilencer_error(t *testing.T) {
+	var errs []error
+	s := NewSilencer(ModuleKV{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		return nil, fmt.Errorf("err1")
+	}}, &SilencerOptions{OnError: func(err error) {
+		errs = append(errs, err)
+	}})
+
+	var calls []string
+
+	m := ModuleAlert{
+		rf: func(path, contentType string, body []byte) ([]byte, error) {
+			calls = append(calls, path)
+			return []byte(`{"level_was_updated":true,"alert":{"name":"a","level":1}}`), nil
+		},
+	}.WithSilencer(s)
+
+	_, _, err := m.Success("a", "m", nil)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if fmt.Sprint(calls) != "[alert/success/a]" {
+		t.Fatalf("unexpected calls, got %v", calls)
+	}
+	if len(errs) != 1 || errs[0].Error() != "failed to load silences: err1" {
+		t.Fatalf("unexpected errors value, got %v", errs)
+	}
+}
@@ -3,8 +3,15 @@ package coreapi
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// defaultTLSPort is the port of the target without the port
+const defaultTLSPort = 443
+
 type ModuleTLS struct {
 	rf requestFunc
 }
@@ -14,13 +21,106 @@ type TLSResult struct {
 	Expiry         int64    `json:"expiry"`
 	DNSNames       []string `json:"dns_names"`
 	EmailAddresses []string `json:"email_addresses"`
+	// Subject, SerialNumber and NotBefore are empty, if the balerter server does not supply them
+	Subject      string `json:"subject"`
+	SerialNumber string `json:"serial_number"`
+	NotBefore    int64  `json:"not_before"`
 }
 
-// Get returns TLS info for the hostname. You should to define a hostname without the protocol and/or the port
-func (t *ModuleTLS) Get(hostname string) ([]TLSResult, error) {
-	respBody, err := t.rf("tls/get", "text/plain", []byte(hostname))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tls info: %w", err)
+// ExpiresAt returns the expiry time of the certificate
+func (r TLSResult) ExpiresAt() time.Time {
+	return time.Unix(r.Expiry, 0)
+}
+
+// NotBeforeAt returns the time the certificate is valid from. It is zero, if the server does not supply it.
+func (r TLSResult) NotBeforeAt() time.Time {
+	if r.NotBefore == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.NotBefore, 0)
+}
+
+// TimeLeft returns the time left to the expiry. It is negative for the expired certificate.
+func (r TLSResult) TimeLeft() time.Duration {
+	return time.Until(r.ExpiresAt())
+}
+
+// CoversHost reports whether the certificate DNS names cover the host name.
+// The wildcard name, e.g. "*.example.com", covers exactly one left-most label, e.g. "www.example.com",
+// but not "example.com" or "a.b.example.com". The names are compared case-insensitively.
+func (r TLSResult) CoversHost(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return false
+	}
+	for _, dnsName := range r.DNSNames {
+		dnsName = strings.ToLower(strings.TrimSuffix(dnsName, "."))
+		if dnsName == name {
+			return true
+		}
+		if !strings.HasPrefix(dnsName, "*.") {
+			continue
+		}
+		i := strings.IndexByte(name, '.')
+		if i > 0 && name[i:] == dnsName[1:] && net.ParseIP(name) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSOptions are options for the TLS request
+type TLSOptions struct {
+	// ServerName is the SNI server name, the host by default
+	ServerName string
+}
+
+// Get returns TLS info for the target. The target is a hostname or host:port, e.g. "example.com:8443",
+// without the protocol. The default port is 443.
+func (t *ModuleTLS) Get(target string) ([]TLSResult, error) {
+	return t.GetWithOptions(target, nil)
+}
+
+// GetWithOptions returns TLS info for the target with the options. opts may be nil.
+// The plain hostname, or the host with the default port, without the server name is requested with tls/get,
+// so it is supported by all balerter servers. Otherwise, the target is requested with tls/get_target,
+// and the error wraps ErrNotSupported, if the balerter server does not support it.
+func (t *ModuleTLS) GetWithOptions(target string, opts *TLSOptions) ([]TLSResult, error) {
+	host, port, errTarget := splitTLSTarget(target)
+	if errTarget != nil {
+		return nil, errTarget
+	}
+
+	serverName := ""
+	if opts != nil {
+		serverName = opts.ServerName
+	}
+
+	var respBody []byte
+	var err error
+
+	if (port == 0 || port == defaultTLSPort) && serverName == "" {
+		respBody, err = t.rf("tls/get", "text/plain", []byte(host))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tls info: %w", err)
+		}
+	} else {
+		payload, errMarshal := json.Marshal(struct {
+			Host       string `json:"host"`
+			Port       int    `json:"port,omitempty"`
+			ServerName string `json:"server_name,omitempty"`
+		}{
+			Host:       host,
+			Port:       port,
+			ServerName: serverName,
+		})
+		if errMarshal != nil {
+			return nil, fmt.Errorf("request marshal error, %w", errMarshal)
+		}
+		respBody, err = t.rf("tls/get_target", "application/json", payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tls info with the port or the server name: %w", err)
+		}
 	}
 
 	var resp []TLSResult
@@ -32,3 +132,35 @@ func (t *ModuleTLS) Get(hostname string) ([]TLSResult, error) {
 
 	return resp, nil
 }
+
+// splitTLSTarget splits the target to the host and the port. The port is zero, if it is not defined.
+func splitTLSTarget(target string) (string, int, error) {
+	if target == "" {
+		return "", 0, fmt.Errorf("tls target must not be empty")
+	}
+	if strings.Contains(target, "/") {
+		return "", 0, fmt.Errorf("tls target %q must not contain the protocol or the path", target)
+	}
+	if net.ParseIP(target) != nil || !strings.Contains(target, ":") {
+		return target, 0, nil
+	}
+	// the bracketed IPv6 address without the port
+	if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		if ip := net.ParseIP(target[1 : len(target)-1]); ip != nil && ip.To4() == nil {
+			return target[1 : len(target)-1], 0, nil
+		}
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad tls target %q: %w", target, err)
+	}
+	port, errPort := strconv.Atoi(portStr)
+	if errPort != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("bad tls target %q: invalid port %q", target, portStr)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("bad tls target %q: empty host", target)
+	}
+	return host, port, nil
+}
@@ -1,8 +1,10 @@
 package coreapi
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestModuleTLS_Get_error(t *testing.T) {
@@ -86,3 +88,118 @@ func TestModuleTLS_Get(t *testing.T) {
 		t.Fatalf("unexpected email_addresses value, got %v", resp[0].EmailAddresses)
 	}
 }
+
+func TestModuleTLS_GetWithOptions(t *testing.T) {
+	var bodies []string
+	m := ModuleTLS{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		bodies = append(bodies, path+" "+contentType+" "+string(body))
+		return []byte(`[{"subject":"CN=a.com","serial_number":"0a","not_before":10}]`), nil
+	}}
+
+	resp, err := m.Get("a.com:8443")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	if resp[0].Subject != "CN=a.com" || resp[0].SerialNumber != "0a" || resp[0].NotBefore != 10 {
+		t.Fatalf("unexpected response, got %+v", resp[0])
+	}
+
+	m.Get("[::1]:8443")
+	m.Get("[::1]:443")
+	m.Get("[::1]")
+	m.Get("::1")
+	m.Get("a.com:443")
+	m.GetWithOptions("10.0.0.1", &TLSOptions{ServerName: "a.com"})
+	m.GetWithOptions("a.com", &TLSOptions{})
+
+	expect := []string{
+		`tls/get_target application/json {"host":"a.com","port":8443}`,
+		`tls/get_target application/json {"host":"::1","port":8443}`,
+		`tls/get text/plain ::1`,
+		`tls/get text/plain ::1`,
+		`tls/get text/plain ::1`,
+		`tls/get text/plain a.com`,
+		`tls/get_target application/json {"host":"10.0.0.1","server_name":"a.com"}`,
+		`tls/get text/plain a.com`,
+	}
+	if fmt.Sprint(bodies) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected bodies, got %q", bodies)
+	}
+}
+
+func TestModuleTLS_GetWithOptions_not_supported(t *testing.T) {
+	m := ModuleTLS{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		if path != "tls/get_target" {
+			t.Fatalf("unexpected path value, got %s", path)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, path)
+	}}
+
+	_, err := m.Get("a.com:8443")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	expect := "failed to get tls info with the port or the server name: method is not supported by the balerter server: tls/get_target"
+	if err.Error() != expect {
+		t.Fatalf("unexpected error value, got %s", err.Error())
+	}
+}
+
+func TestModuleTLS_Get_bad_target(t *testing.T) {
+	m := ModuleTLS{rf: func(path, contentType string, body []byte) ([]byte, error) {
+		t.Fatalf("unexpected call")
+		return nil, nil
+	}}
+
+	for target, expect := range map[string]string{
+		"":              "tls target must not be empty",
+		"https://a.com": `tls target "https://a.com" must not contain the protocol or the path`,
+		"a.com:x":       `bad tls target "a.com:x": invalid port "x"`,
+		"a.com:70000":   `bad tls target "a.com:70000": invalid port "70000"`,
+		":443":          `bad tls target ":443": empty host`,
+		"a.com:443:1":   `bad tls target "a.com:443:1": address a.com:443:1: too many colons in address`,
+	} {
+		_, err := m.Get(target)
+		if err == nil || err.Error() != expect {
+			t.Fatalf("unexpected error value for %q, got %v", target, err)
+		}
+	}
+}
+
+func TestTLSResult_time(t *testing.T) {
+	r := TLSResult{Expiry: time.Now().Add(time.Hour).Unix()}
+
+	if r.ExpiresAt().Unix() != r.Expiry {
+		t.Fatalf("unexpected expiry, got %s", r.ExpiresAt())
+	}
+	if left := r.TimeLeft(); left <= 59*time.Minute || left > time.Hour {
+		t.Fatalf("unexpected time left, got %s", left)
+	}
+	if !r.NotBeforeAt().IsZero() {
+		t.Fatalf("unexpected not before, got %s", r.NotBeforeAt())
+	}
+	r.NotBefore = 10
+	if r.NotBeforeAt().Unix() != 10 {
+		t.Fatalf("unexpected not before, got %s", r.NotBeforeAt())
+	}
+}
+
+func TestTLSResult_CoversHost(t *testing.T) {
+	r := TLSResult{DNSNames: []string{"Example.com", "*.api.example.com", "10.0.0.1"}}
+
+	for name, expect := range map[string]bool{
+		"example.com":          true,
+		"EXAMPLE.COM.":         true,
+		"www.example.com":      false,
+		"v1.api.example.com":   true,
+		"api.example.com":      false,
+		"a.v1.api.example.com": false,
+		".api.example.com":     false,
+		"10.0.0.1":             true,
+		"":                     false,
+	} {
+		if r.CoversHost(name) != expect {
+			t.Fatalf("unexpected covers value for %q, got %v", name, !expect)
+		}
+	}
+}